@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+)
+
+// 规则缓存的定时刷新间隔，多实例部署时其他实例修改的规则最迟在该间隔后生效
+const autoReplyReloadInterval = time.Minute
+
+// compiledRule 已加载的规则，regex 规则预先编译
+type compiledRule struct {
+	*db.AutoReplyRule
+	re *regexp.Regexp
+}
+
+// AutoReply 关键词自动回复，规则存储在 MySQL 并缓存在内存中
+type AutoReply struct {
+	appID string
+
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+// NewAutoReply 创建自动回复并加载规则，appid 为空表示默认公众号
+func NewAutoReply(appid string) *AutoReply {
+	a := &AutoReply{appID: appid}
+	if err := a.reload(); err != nil {
+		logger.Errorf("[autoreply] 加载自动回复规则失败: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(autoReplyReloadInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := a.reload(); err != nil {
+				logger.Errorf("[autoreply] 刷新自动回复规则失败: %v", err)
+			}
+		}
+	}()
+	return a
+}
+
+// reload 从 MySQL 重新加载启用的规则
+func (a *AutoReply) reload() error {
+	rules, err := db.ListAutoReplyRules(a.appID, true)
+	if err != nil {
+		return err
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		cr := compiledRule{AutoReplyRule: r}
+		if r.RuleType == db.RuleTypeKeyword && r.MatchType == "regex" {
+			if cr.re, err = regexp.Compile(r.Keyword); err != nil {
+				logger.Warnf("[autoreply] 规则 %d 正则表达式无效，已忽略: %v", r.ID, err)
+				continue
+			}
+		}
+		compiled = append(compiled, cr)
+	}
+
+	a.mu.Lock()
+	a.rules = compiled
+	a.mu.Unlock()
+	return nil
+}
+
+// Register 在回调服务上注册自动回复：文本消息按关键词匹配，其他消息与未匹配的文本使用默认回复，关注时发送关注回复
+func (a *AutoReply) Register(s *WechatServer) {
+	for _, t := range []string{vxmsg.MsgTypeText, vxmsg.MsgTypeImage, vxmsg.MsgTypeVoice, vxmsg.MsgTypeLocation, vxmsg.MsgTypeLink} {
+		s.HandleMsg(t, a.handleMessage)
+	}
+	s.HandleEvent(vxmsg.EventSubscribe, a.handleSubscribe)
+}
+
+func (a *AutoReply) handleMessage(msg *vxmsg.CallbackMessage) vxmsg.Reply {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if msg.MsgType == vxmsg.MsgTypeText {
+		content := strings.TrimSpace(msg.Content)
+		for _, r := range a.rules {
+			if r.RuleType == db.RuleTypeKeyword && r.match(content) {
+				return buildAutoReply(msg, r.AutoReplyRule)
+			}
+		}
+	}
+	return a.firstOfType(msg, db.RuleTypeDefault)
+}
+
+func (a *AutoReply) handleSubscribe(msg *vxmsg.CallbackMessage) vxmsg.Reply {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.firstOfType(msg, db.RuleTypeSubscribe)
+}
+
+// firstOfType 使用优先级最高的指定类型规则回复，调用方需持有读锁
+func (a *AutoReply) firstOfType(msg *vxmsg.CallbackMessage, ruleType string) vxmsg.Reply {
+	for _, r := range a.rules {
+		if r.RuleType == ruleType {
+			return buildAutoReply(msg, r.AutoReplyRule)
+		}
+	}
+	return nil
+}
+
+func (r compiledRule) match(content string) bool {
+	switch r.MatchType {
+	case "exact":
+		return content == r.Keyword
+	case "prefix":
+		return strings.HasPrefix(content, r.Keyword)
+	case "regex":
+		return r.re != nil && r.re.MatchString(content)
+	}
+	return false
+}
+
+// buildAutoReply 按规则的回复类型构造被动回复，内容无效时不回复
+func buildAutoReply(msg *vxmsg.CallbackMessage, r *db.AutoReplyRule) vxmsg.Reply {
+	logger.Infof("[autoreply] 命中规则 %d，openid: %s", r.ID, msg.FromUserName)
+	switch r.ReplyType {
+	case vxmsg.MsgTypeText:
+		return vxmsg.NewTextReply(msg, r.ReplyContent)
+	case vxmsg.MsgTypeImage:
+		return vxmsg.NewImageReply(msg, r.ReplyContent)
+	case "news":
+		var articles []vxmsg.Article
+		if err := json.Unmarshal([]byte(r.ReplyContent), &articles); err != nil || len(articles) == 0 {
+			logger.Warnf("[autoreply] 规则 %d 图文内容无效: %v", r.ID, err)
+			return nil
+		}
+		return vxmsg.NewNewsReply(msg, articles...)
+	}
+	return nil
+}
+
+// autoReplyRuleRequest 管理接口新增 / 修改规则的参数
+type autoReplyRuleRequest struct {
+	RuleType     string `json:"rule_type" binding:"required,oneof=keyword subscribe default"`
+	MatchType    string `json:"match_type" binding:"omitempty,oneof=exact prefix regex"`
+	Keyword      string `json:"keyword" binding:"max=255"`
+	ReplyType    string `json:"reply_type" binding:"required,oneof=text image news"`
+	ReplyContent string `json:"reply_content" binding:"required"`
+	Priority     int    `json:"priority"`
+	Enabled      *bool  `json:"enabled"` // 默认启用
+}
+
+// validate 校验关键词规则与回复内容
+func (req *autoReplyRuleRequest) validate() error {
+	if req.RuleType == db.RuleTypeKeyword {
+		if req.MatchType == "" || req.Keyword == "" {
+			return fmt.Errorf("关键词规则需要 match_type 与 keyword")
+		}
+		if req.MatchType == "regex" {
+			if _, err := regexp.Compile(req.Keyword); err != nil {
+				return fmt.Errorf("正则表达式无效: %v", err)
+			}
+		}
+	}
+	if req.ReplyType == "news" {
+		var articles []vxmsg.Article
+		if err := json.Unmarshal([]byte(req.ReplyContent), &articles); err != nil {
+			return fmt.Errorf("图文内容应为 JSON 数组: %v", err)
+		}
+		if len(articles) != 1 {
+			return fmt.Errorf("被动回复只能包含 1 条图文")
+		}
+	}
+	return nil
+}
+
+func (req *autoReplyRuleRequest) toRule(appid string) *db.AutoReplyRule {
+	r := &db.AutoReplyRule{
+		AppID:        appid,
+		RuleType:     req.RuleType,
+		ReplyType:    req.ReplyType,
+		ReplyContent: req.ReplyContent,
+		Priority:     req.Priority,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if req.RuleType == db.RuleTypeKeyword {
+		r.MatchType, r.Keyword = req.MatchType, req.Keyword
+	}
+	return r
+}
+
+// RegisterAdminRoutes 注册规则管理接口，公众号通过 W-AppID 请求头指定（为空表示默认公众号）
+func (a *AutoReply) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.GET("/rules", a.listRules)
+	rg.POST("/rules", a.createRule)
+	rg.PUT("/rules/:id", a.updateRule)
+	rg.DELETE("/rules/:id", a.deleteRule)
+}
+
+func (a *AutoReply) listRules(c *gin.Context) {
+	rules, err := db.ListAutoReplyRules(requestAppID(c), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询规则失败: " + err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []*db.AutoReplyRule{}
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (a *AutoReply) createRule(c *gin.Context) {
+	var req autoReplyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.toRule(requestAppID(c))
+	id, err := db.CreateAutoReplyRule(rule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "新增规则失败: " + err.Error()})
+		return
+	}
+	logger.Infof("[autoreply] 新增规则 %d，IP: %s", id, c.ClientIP())
+	a.reloadAfterChange(rule.AppID)
+	c.JSON(http.StatusOK, gin.H{"message": "新增成功", "id": id})
+}
+
+func (a *AutoReply) updateRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则 ID 错误"})
+		return
+	}
+	var req autoReplyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appid := requestAppID(c)
+	existing, err := db.GetAutoReplyRule(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询规则失败: " + err.Error()})
+		return
+	}
+	if existing == nil || existing.AppID != appid {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+		return
+	}
+
+	rule := req.toRule(appid)
+	rule.ID = id
+	if err := db.UpdateAutoReplyRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新规则失败: " + err.Error()})
+		return
+	}
+	logger.Infof("[autoreply] 更新规则 %d，IP: %s", id, c.ClientIP())
+	a.reloadAfterChange(appid)
+	c.JSON(http.StatusOK, gin.H{"message": "更新成功"})
+}
+
+func (a *AutoReply) deleteRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则 ID 错误"})
+		return
+	}
+	appid := requestAppID(c)
+	ok, err := db.DeleteAutoReplyRule(appid, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除规则失败: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+		return
+	}
+	logger.Infof("[autoreply] 删除规则 %d，IP: %s", id, c.ClientIP())
+	a.reloadAfterChange(appid)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// reloadAfterChange 修改的是本实例回调所属公众号的规则时立即刷新缓存
+func (a *AutoReply) reloadAfterChange(appid string) {
+	if appid != a.appID {
+		return
+	}
+	if err := a.reload(); err != nil {
+		logger.Errorf("[autoreply] 刷新自动回复规则失败: %v", err)
+	}
+}
@@ -2,23 +2,154 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"context"
 	"net/http"
 	"sort"
 	"crypto/sha1"
+	"encoding/xml"
 	"fmt"
 	"strings"
 	"io/ioutil"
+	"strconv"
+	"time"
 
+	"vxmsgpush/config"
+	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
 	"vxmsgpush/logger"  // 引入你的日志模块
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultTimestampWindow = 5 * time.Minute
+	defaultDedupTTL        = 5 * time.Minute
+	callbackDedupPrefix    = "wx_callback_dedup"
+	legacyCallbackToken    = "SmileSion" // 升级前写死的回调令牌，未配置 callback.token 时使用
+
+	// 去重记录的状态：处理中的记录 TTL 较短，进程中途退出后微信的重试推送可以重新处理
+	dedupStateProcessing  = "processing"
+	dedupStateDone        = "done"
+	callbackProcessingTTL = 10 * time.Second
 )
 
+// MessageHandler 处理一条微信推送，返回 nil 表示不被动回复
+type MessageHandler func(msg *vxmsg.CallbackMessage) vxmsg.Reply
+
 // 微信公众号验证结构体
 type WechatServer struct {
 	Token string
+	AppID string // 回调所属公众号，默认公众号为空（见 config.NormalizeAppID），与队列消息的 appid 一致
+
+	mode    string            // 消息加解密方式，见 vxmsg.CallbackMode*
+	crypter *vxmsg.MsgCrypter // 兼容模式与安全模式下使用
+
+	// 重放保护：拒绝时间戳偏差超过 timestampWindow 的请求，并在 Redis 中记录已处理的消息用于去重
+	timestampWindow time.Duration
+	dedupTTL        time.Duration
+	rdb             *redis.Client // 为 nil 时不去重
+
+	// 按 MsgType / Event 注册的处理函数，需在启动服务前注册
+	routes         map[string][]MessageHandler
+	defaultHandler MessageHandler
+
+	// 内置的事件记录（送达状态、关注状态），按事件类型注册，失败时让微信重试
+	recorders map[string]func(msg *vxmsg.CallbackMessage) error
 }
 
 func NewWechatServer(token string) *WechatServer {
-	return &WechatServer{Token: token}
+	s := &WechatServer{
+		Token:           token,
+		mode:            vxmsg.CallbackModePlain,
+		timestampWindow: defaultTimestampWindow,
+		dedupTTL:        defaultDedupTTL,
+		routes:          make(map[string][]MessageHandler),
+	}
+	s.recorders = map[string]func(msg *vxmsg.CallbackMessage) error{
+		vxmsg.EventTemplateSendJobFinish: s.handleTemplateSendJobFinish,
+		vxmsg.EventSubscribe:             s.handleSubscribe,
+		vxmsg.EventUnsubscribe:           s.handleUnsubscribe,
+	}
+	return s
+}
+
+// NewWechatServerFromConfig 按 [callback] 配置创建回调服务，兼容模式与安全模式需配置 EncodingAESKey
+func NewWechatServerFromConfig(conf config.CallbackConfig) (*WechatServer, error) {
+	if conf.Token == "" {
+		if conf.Mode != "" && conf.Mode != vxmsg.CallbackModePlain {
+			return nil, fmt.Errorf("未配置 callback.token")
+		}
+		// 兼容升级前没有 [callback] 配置的部署，沿用原先固定的令牌
+		logger.Warnf("未配置 callback.token，使用升级前的默认令牌，请尽快在 [callback] 中配置")
+		conf.Token = legacyCallbackToken
+	}
+	s := NewWechatServer(conf.Token)
+	s.AppID = config.NormalizeAppID(conf.AppID)
+	s.rdb = consumer.RDB
+	if conf.TimestampWindow > 0 {
+		s.timestampWindow = time.Duration(conf.TimestampWindow) * time.Second
+	}
+	if conf.DedupTTL > 0 {
+		s.dedupTTL = time.Duration(conf.DedupTTL) * time.Second
+	}
+
+	switch conf.Mode {
+	case "", vxmsg.CallbackModePlain:
+		return s, nil
+	case vxmsg.CallbackModeCompatible, vxmsg.CallbackModeSafe:
+		s.mode = conf.Mode
+	default:
+		return nil, fmt.Errorf("不支持的消息加解密方式: %s", conf.Mode)
+	}
+
+	appID := conf.AppID
+	if appID == "" {
+		appID = config.Conf.VxKey.AppId
+	}
+	crypter, err := vxmsg.NewMsgCrypter(conf.Token, conf.EncodingAESKey, appID)
+	if err != nil {
+		return nil, err
+	}
+	s.crypter = crypter
+	return s, nil
+}
+
+// HandleMsg 注册普通消息处理函数（text / image / voice / location / link）
+func (s *WechatServer) HandleMsg(msgType string, h MessageHandler) {
+	s.routes[msgType] = append(s.routes[msgType], h)
+}
+
+// HandleEvent 注册事件处理函数（subscribe / unsubscribe / SCAN / CLICK / VIEW 等）
+func (s *WechatServer) HandleEvent(event string, h MessageHandler) {
+	key := vxmsg.MsgTypeEvent + ":" + event
+	s.routes[key] = append(s.routes[key], h)
+}
+
+// HandleDefault 注册没有匹配处理函数时的兜底处理
+func (s *WechatServer) HandleDefault(h MessageHandler) {
+	s.defaultHandler = h
+}
+
+// dispatch 依次调用匹配的处理函数，使用第一个非 nil 的回复
+func (s *WechatServer) dispatch(msg *vxmsg.CallbackMessage) vxmsg.Reply {
+	key := msg.MsgType
+	if msg.MsgType == vxmsg.MsgTypeEvent {
+		key = vxmsg.MsgTypeEvent + ":" + msg.Event
+	}
+
+	handlers := s.routes[key]
+	if len(handlers) == 0 && s.defaultHandler != nil {
+		handlers = []MessageHandler{s.defaultHandler}
+	}
+
+	var reply vxmsg.Reply
+	for _, h := range handlers {
+		if r := h(msg); r != nil && reply == nil {
+			reply = r
+		}
+	}
+	return reply
 }
 
 func (s *WechatServer) RegisterRoutes(rg *gin.RouterGroup) {
@@ -45,6 +176,17 @@ func (s *WechatServer) handleGet(c *gin.Context) {
 
 // POST 接收微信消息推送
 func (s *WechatServer) handlePost(c *gin.Context) {
+	if !s.checkSignature(c.Query("signature"), c.Query("timestamp"), c.Query("nonce")) {
+		logger.Warnf("微信推送签名校验失败，IP: %s", c.ClientIP())
+		c.String(http.StatusForbidden, "验证失败")
+		return
+	}
+	if !s.checkTimestamp(c.Query("timestamp")) {
+		logger.Warnf("微信推送时间戳超出允许范围，IP: %s，timestamp: %s", c.ClientIP(), c.Query("timestamp"))
+		c.String(http.StatusForbidden, "请求已过期")
+		return
+	}
+
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		logger.Errorf("读取请求体失败: %v", err)
@@ -53,8 +195,178 @@ func (s *WechatServer) handlePost(c *gin.Context) {
 	}
 	logger.Infof("收到微信推送消息: %s", string(body))
 
-	// 这里可以扩展消息处理逻辑，暂时回复固定内容
-	c.String(http.StatusOK, "收到消息")
+	// 兼容模式与安全模式下微信以 encrypt_type=aes 标识密文消息
+	encrypted := c.Query("encrypt_type") == "aes"
+	if s.mode == vxmsg.CallbackModeSafe && !encrypted {
+		logger.Warnf("安全模式下收到明文消息，IP: %s", c.ClientIP())
+		c.String(http.StatusForbidden, "仅接受加密消息")
+		return
+	}
+	if encrypted {
+		if s.crypter == nil {
+			logger.Warnf("收到加密消息但未启用加解密，IP: %s", c.ClientIP())
+			c.String(http.StatusBadRequest, "未启用消息加解密")
+			return
+		}
+		if body, err = s.decrypt(c, body); err != nil {
+			logger.Errorf("解密微信推送消息失败，IP: %s: %v", c.ClientIP(), err)
+			c.String(http.StatusForbidden, "解密失败")
+			return
+		}
+		logger.Debugf("解密后的微信推送消息: %s", string(body))
+	}
+
+	msg, err := vxmsg.ParseCallbackMessage(body)
+	if err != nil {
+		logger.Errorf("%v，内容: %s", err, string(body))
+		c.String(http.StatusBadRequest, "消息格式错误")
+		return
+	}
+
+	// 微信 5 秒内未收到回复会重试，已处理或正在处理的重复推送直接回复 success，避免重复执行
+	dedupKey, ok := s.beginProcessing(msg)
+	if !ok {
+		logger.Infof("忽略重复推送的微信消息: %s", msg.DedupID())
+		c.String(http.StatusOK, "success")
+		return
+	}
+	done := false
+	defer func() {
+		// 处理未完成（出错或 panic）时清除去重记录，微信重试时重新处理
+		if !done {
+			s.releaseDedup(dedupKey)
+		}
+	}()
+
+	// 记录送达 / 关注状态，失败时不标记为已处理，由微信重试
+	if record, ok := s.recorders[msg.Event]; ok && msg.MsgType == vxmsg.MsgTypeEvent {
+		if err := record(msg); err != nil {
+			logger.Errorf("处理微信事件 %s 失败，等待微信重试: %v", msg.Event, err)
+			c.String(http.StatusInternalServerError, "处理失败")
+			return
+		}
+	}
+
+	// 转发给订阅的业务系统（仅入队，不等待下游响应）
+	if err := consumer.EnqueueWebhooks(s.AppID, msg); err != nil {
+		logger.Errorf("回调转发入队失败，等待微信重试: %v", err)
+		c.String(http.StatusInternalServerError, "处理失败")
+		return
+	}
+
+	reply := s.dispatch(msg)
+	s.finishProcessing(dedupKey)
+	done = true
+	if reply == nil {
+		// 不需要回复时返回 success，微信不会重试也不会提示用户
+		c.String(http.StatusOK, "success")
+		return
+	}
+
+	data, err := reply.Bytes()
+	if err == nil && encrypted {
+		data, err = s.crypter.EncryptReply(data, c.Query("timestamp"), c.Query("nonce"))
+	}
+	if err != nil {
+		logger.Errorf("被动回复序列化失败: %v", err)
+		c.String(http.StatusOK, "success")
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+}
+
+// decrypt 校验 msg_signature 并解密消息外层的 Encrypt 字段
+func (s *WechatServer) decrypt(c *gin.Context, body []byte) ([]byte, error) {
+	var env vxmsg.EncryptedMessage
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("解析加密消息失败: %v", err)
+	}
+	if env.Encrypt == "" {
+		return nil, fmt.Errorf("缺少 Encrypt 字段")
+	}
+	if s.crypter.Signature(c.Query("timestamp"), c.Query("nonce"), env.Encrypt) != c.Query("msg_signature") {
+		return nil, fmt.Errorf("msg_signature 校验失败")
+	}
+	return s.crypter.Decrypt(env.Encrypt)
+}
+
+// handleTemplateSendJobFinish 记录模板消息最终送达状态，按 appid + msgid 与发送记录关联
+func (s *WechatServer) handleTemplateSendJobFinish(msg *vxmsg.CallbackMessage) error {
+	logger.Infof("模板消息送达回调，msgid: %d，openid: %s，状态: %s", msg.TemplateMsgID, msg.FromUserName, msg.Status)
+	if err := db.SaveTemplateDelivery(s.AppID, msg.TemplateMsgID, msg.FromUserName, msg.Status, time.Unix(msg.CreateTime, 0)); err != nil {
+		return fmt.Errorf("记录模板消息送达状态失败，msgid: %d: %v", msg.TemplateMsgID, err)
+	}
+	consumer.AddDelivery(msg.Status)
+	return nil
+}
+
+// handleSubscribe 记录用户关注，之后的消息恢复正常发送
+func (s *WechatServer) handleSubscribe(msg *vxmsg.CallbackMessage) error {
+	scene := strings.TrimPrefix(msg.EventKey, "qrscene_")
+	if err := db.SaveFollowerSubscribe(s.AppID, msg.FromUserName, scene, time.Unix(msg.CreateTime, 0)); err != nil {
+		return fmt.Errorf("记录用户关注失败，openid: %s: %v", msg.FromUserName, err)
+	}
+	return nil
+}
+
+// handleUnsubscribe 记录用户取消关注，消费者据此跳过发给该用户的消息
+func (s *WechatServer) handleUnsubscribe(msg *vxmsg.CallbackMessage) error {
+	if err := db.SaveFollowerUnsubscribe(s.AppID, msg.FromUserName, time.Unix(msg.CreateTime, 0)); err != nil {
+		return fmt.Errorf("记录用户取消关注失败，openid: %s: %v", msg.FromUserName, err)
+	}
+	return nil
+}
+
+// checkTimestamp 校验请求时间戳在允许范围内
+func (s *WechatServer) checkTimestamp(timestamp string) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	diff := time.Since(time.Unix(ts, 0))
+	return diff <= s.timestampWindow && diff >= -s.timestampWindow
+}
+
+// beginProcessing 在 Redis 中以“处理中”状态记录消息 ID（短 TTL，进程中途退出时自动失效），
+// 记录已存在（处理中或已处理）时返回 false；不去重或 Redis 不可用时返回空 key 并放行
+func (s *WechatServer) beginProcessing(msg *vxmsg.CallbackMessage) (string, bool) {
+	if s.rdb == nil {
+		return "", true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	key := fmt.Sprintf("%s:%s:%s", callbackDedupPrefix, s.AppID, msg.DedupID())
+	ok, err := s.rdb.SetNX(ctx, key, dedupStateProcessing, callbackProcessingTTL).Result()
+	if err != nil {
+		logger.Warnf("消息去重检查失败，继续处理: %v", err)
+		return "", true
+	}
+	return key, ok
+}
+
+// finishProcessing 处理完成后将记录改为“已处理”，保留 dedupTTL
+func (s *WechatServer) finishProcessing(key string) {
+	if key == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.rdb.Set(ctx, key, dedupStateDone, s.dedupTTL).Err(); err != nil {
+		logger.Warnf("记录消息处理状态失败: %v", err)
+	}
+}
+
+// releaseDedup 处理失败时删除记录，使微信的重试推送可以重新处理
+func (s *WechatServer) releaseDedup(key string) {
+	if key == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.rdb.Del(ctx, key).Err(); err != nil {
+		logger.Warnf("清除消息去重记录失败: %v", err)
+	}
 }
 
 func (s *WechatServer) checkSignature(signature, timestamp, nonce string) bool {
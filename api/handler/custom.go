@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+)
+
+// CustomMessageRequest 客服消息请求，touser 为空时按 mobile 查询 openid，其余字段与微信客服消息格式一致
+type CustomMessageRequest struct {
+	RequestID string `json:"request_id" binding:"omitempty,max=64"` // 为空时自动生成，可据此查询投递记录
+	Mobile    string `json:"mobile"`
+	vxmsg.CustomMsg
+}
+
+// redisCustomMessage 客服消息在队列中的格式，对应 consumer.RedisMessage
+type redisCustomMessage struct {
+	Type      string           `json:"type"`
+	RequestID string           `json:"request_id"`
+	Mobile    string           `json:"mobile"`
+	AppID     string           `json:"appid,omitempty"`
+	Custom    *vxmsg.CustomMsg `json:"custom"`
+}
+
+// TypingRequest 输入状态请求
+type TypingRequest struct {
+	Mobile string `json:"mobile"`
+	ToUser string `json:"touser"`
+	Typing bool   `json:"typing"` // true 正在输入，false 取消
+}
+
+// PushCustomHandler 校验客服消息（图文、图片、语音、视频、音乐、菜单、小程序卡片等）后存入 Redis 队列
+func PushCustomHandler(c *gin.Context) {
+	clientIP := c.ClientIP()
+	var req CustomMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warnf("请求参数格式错误，IP: %s，错误: %v", clientIP, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	if req.ToUser == "" && req.Mobile == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "touser 与 mobile 不能同时为空"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RequestID == "" {
+		req.RequestID = consumer.NewRequestID()
+	}
+	jsonBytes, err := json.Marshal(redisCustomMessage{
+		Type:      consumer.MsgTypeCustom,
+		RequestID: req.RequestID,
+		Mobile:    req.Mobile,
+		AppID:     requestAppID(c),
+		Custom:    &req.CustomMsg,
+	})
+	if err != nil {
+		logger.Errorf("请求序列化失败，IP: %s，错误: %v", clientIP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "序列化失败: " + err.Error()})
+		return
+	}
+
+	logger.Infof("接收到客服消息请求，IP: %s，内容: %s", clientIP, string(jsonBytes))
+
+	if err := consumer.RDB.RPush(context.Background(), messageQueue, jsonBytes).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "写入 Redis 失败: " + err.Error()})
+		return
+	}
+
+	logger.Infof("客服消息成功入队，IP: %s, RequestID: %s", clientIP, req.RequestID)
+	c.JSON(http.StatusOK, gin.H{"message": "消息入队成功", "request_id": req.RequestID})
+}
+
+// PushTypingHandler 下发或取消“正在输入”状态（实时调用，不经过队列）
+func PushTypingHandler(c *gin.Context) {
+	var req TypingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+
+	openid := req.ToUser
+	if openid == "" && req.Mobile != "" {
+		var err error
+		if openid, err = vxmsg.GetUserOpenIDByMobile(req.Mobile); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户OpenID失败: " + err.Error()})
+			return
+		}
+	}
+	if openid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "touser 与 mobile 不能同时为空"})
+		return
+	}
+
+	if err := vxmsg.SendTypingWithAppID(requestAppID(c), openid, req.Typing); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "下发输入状态失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "下发成功"})
+}
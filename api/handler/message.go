@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+)
+
+// GetMessageRecordHandler 按请求 ID 查询单条消息的投递记录
+func GetMessageRecordHandler(c *gin.Context) {
+	requestID := c.Param("request_id")
+	rec, err := db.GetMessageRecord(requestID)
+	if err != nil {
+		logger.Errorf("查询消息记录失败，request_id: %s，错误: %v", requestID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+	if rec == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "消息记录不存在"})
+		return
+	}
+
+	var normalized []vxmsg.FieldChange
+	if rec.Normalized != "" {
+		json.Unmarshal([]byte(rec.Normalized), &normalized)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"request_id":      rec.RequestID,
+		"msg_type":        rec.MsgType,
+		"appid":           rec.AppID,
+		"mobile":          rec.Mobile,
+		"openid":          rec.OpenID,
+		"template_id":     rec.TemplateID,
+		"msgid":           rec.MsgID,
+		"status":          rec.Status,
+		"delivery_status": rec.DeliveryStatus,
+		"attempts":        rec.Attempts,
+		"err_msg":         rec.ErrMsg,
+		"normalized":      normalized,
+		"created_at":      rec.CreatedAt.Format("2006-01-02 15:04:05"),
+		"updated_at":      rec.UpdatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
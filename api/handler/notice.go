@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+)
+
+// parseNoticeText 解析通知模板中的 text/template 表达式，引用不存在的变量时渲染失败（可选变量使用 index . "name"）
+func parseNoticeText(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// renderNoticeText 渲染单个表达式，结果去除首尾空白
+func renderNoticeText(name, text string, vars map[string]interface{}) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tpl, err := parseNoticeText(name, text)
+	if err != nil {
+		return "", fmt.Errorf("解析 %s 失败: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("渲染 %s 失败: %v", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RenderNotice 用业务变量渲染通知模板，填充 req 的消息类型、template_id、data 与跳转目标；
+// req 中已指定的 url、page、miniprogram 优先于通知模板的默认值
+func RenderNotice(n *db.NoticeTemplate, vars map[string]interface{}, req *RedisTemplateMessage) error {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(n.Fields), &fields); err != nil {
+		return fmt.Errorf("通知模板字段配置无效: %v", err)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data := make(map[string]interface{}, len(fields))
+	for _, key := range keys {
+		value, err := renderNoticeText(key, fields[key], vars)
+		if err != nil {
+			return err
+		}
+		data[key] = map[string]interface{}{"value": value}
+	}
+
+	var err error
+	if req.URL == "" {
+		if req.URL, err = renderNoticeText("url", n.URL, vars); err != nil {
+			return err
+		}
+	}
+	if req.Page == "" {
+		if req.Page, err = renderNoticeText("page", n.Page, vars); err != nil {
+			return err
+		}
+	}
+	if req.MiniProgram == nil && n.MiniProgramAppID != "" {
+		pagePath, err := renderNoticeText("miniprogram_pagepath", n.MiniProgramPagePath, vars)
+		if err != nil {
+			return err
+		}
+		req.MiniProgram = &MiniProgram{AppID: n.MiniProgramAppID, PagePath: pagePath}
+	}
+
+	req.Kind = n.Kind
+	req.TemplateID = n.TemplateID
+	req.Data = data
+	return nil
+}
+
+// noticeView 管理接口返回的通知模板
+type noticeView struct {
+	*db.NoticeTemplate
+	Fields      map[string]string `json:"fields"`
+	MiniProgram *MiniProgram      `json:"miniprogram,omitempty"`
+}
+
+func newNoticeView(n *db.NoticeTemplate) noticeView {
+	v := noticeView{NoticeTemplate: n}
+	json.Unmarshal([]byte(n.Fields), &v.Fields)
+	if n.MiniProgramAppID != "" {
+		v.MiniProgram = &MiniProgram{AppID: n.MiniProgramAppID, PagePath: n.MiniProgramPagePath}
+	}
+	return v
+}
+
+// noticeTemplateRequest 管理接口新增 / 修改通知模板的参数
+type noticeTemplateRequest struct {
+	Name        string            `json:"name" binding:"omitempty,max=64"` // 新增时必填，修改时以路径中的名称为准
+	Kind        string            `json:"kind" binding:"omitempty,oneof=template subscribe"`
+	TemplateID  string            `json:"template_id" binding:"required"`
+	Fields      map[string]string `json:"fields" binding:"required"`
+	URL         string            `json:"url" binding:"max=1024"`
+	Page        string            `json:"page" binding:"max=1024"`
+	MiniProgram *MiniProgram      `json:"miniprogram"`
+	Description string            `json:"description" binding:"max=255"`
+	Enabled     *bool             `json:"enabled"` // 默认启用
+}
+
+// validate 校验表达式语法，模板消息另校验字段是否为模板中声明的字段
+func (req *noticeTemplateRequest) validate(c *gin.Context, appid string) error {
+	if len(req.Fields) == 0 {
+		return fmt.Errorf("fields 不能为空")
+	}
+	texts := map[string]string{"url": req.URL, "page": req.Page}
+	if req.MiniProgram != nil {
+		texts["miniprogram_pagepath"] = req.MiniProgram.PagePath
+	}
+	for key, text := range req.Fields {
+		texts["fields."+key] = text
+	}
+	for name, text := range texts {
+		if _, err := parseNoticeText(name, text); err != nil {
+			return fmt.Errorf("%s 表达式无效: %v", name, err)
+		}
+	}
+
+	if req.Kind == consumer.MsgTypeSubscribe {
+		return nil
+	}
+	keywords, err := TemplateKeywords(c.Request.Context(), appid, req.TemplateID)
+	if err != nil {
+		logger.Warnf("获取模板 %s 定义失败，跳过字段检查: %v", req.TemplateID, err)
+		return nil
+	}
+	if keywords == nil {
+		return fmt.Errorf("模板不存在: %s", req.TemplateID)
+	}
+	declared := make(map[string]bool, len(keywords))
+	for _, kw := range keywords {
+		declared[kw.Key] = true
+	}
+	for key := range req.Fields {
+		if !declared[key] {
+			return fmt.Errorf("模板 %s 中没有字段 %s", req.TemplateID, key)
+		}
+	}
+	return nil
+}
+
+func (req *noticeTemplateRequest) toNotice(appid, name string) *db.NoticeTemplate {
+	fields, _ := json.Marshal(req.Fields)
+	n := &db.NoticeTemplate{
+		AppID:       appid,
+		Name:        name,
+		Kind:        req.Kind,
+		TemplateID:  req.TemplateID,
+		Fields:      string(fields),
+		URL:         req.URL,
+		Page:        req.Page,
+		Description: req.Description,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	if n.Kind == "" {
+		n.Kind = consumer.MsgTypeTemplate
+	}
+	if req.MiniProgram != nil {
+		n.MiniProgramAppID, n.MiniProgramPagePath = req.MiniProgram.AppID, req.MiniProgram.PagePath
+	}
+	return n
+}
+
+// RegisterNoticeAdminRoutes 注册通知模板管理接口，公众号通过 W-AppID 请求头指定（为空表示默认公众号）
+func RegisterNoticeAdminRoutes(rg *gin.RouterGroup) {
+	rg.GET("", listNoticesHandler)
+	rg.POST("", createNoticeHandler)
+	rg.GET("/:name", getNoticeHandler)
+	rg.PUT("/:name", updateNoticeHandler)
+	rg.DELETE("/:name", deleteNoticeHandler)
+	rg.POST("/:name/preview", previewNoticeHandler)
+}
+
+func listNoticesHandler(c *gin.Context) {
+	list, err := db.ListNoticeTemplates(requestAppID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通知模板失败: " + err.Error()})
+		return
+	}
+	views := make([]noticeView, 0, len(list))
+	for _, n := range list {
+		views = append(views, newNoticeView(n))
+	}
+	c.JSON(http.StatusOK, gin.H{"notices": views})
+}
+
+// loadNotice 查询路径中指定的通知模板，失败或不存在时写入响应并返回 nil
+func loadNotice(c *gin.Context) *db.NoticeTemplate {
+	n, err := db.GetNoticeTemplate(requestAppID(c), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通知模板失败: " + err.Error()})
+		return nil
+	}
+	if n == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "通知模板不存在"})
+		return nil
+	}
+	return n
+}
+
+func getNoticeHandler(c *gin.Context) {
+	if n := loadNotice(c); n != nil {
+		c.JSON(http.StatusOK, newNoticeView(n))
+	}
+}
+
+func createNoticeHandler(c *gin.Context) {
+	var req noticeTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name 不能为空"})
+		return
+	}
+	appid := requestAppID(c)
+	if err := req.validate(c, appid); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := db.GetNoticeTemplate(appid, req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通知模板失败: " + err.Error()})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "通知模板已存在: " + req.Name})
+		return
+	}
+
+	id, err := db.CreateNoticeTemplate(req.toNotice(appid, req.Name))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "新增通知模板失败: " + err.Error()})
+		return
+	}
+	logger.Infof("新增通知模板 %s，IP: %s", req.Name, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "新增成功", "id": id})
+}
+
+func updateNoticeHandler(c *gin.Context) {
+	var req noticeTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	appid := requestAppID(c)
+	if err := req.validate(c, appid); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	existing := loadNotice(c)
+	if existing == nil {
+		return
+	}
+
+	if err := db.UpdateNoticeTemplate(req.toNotice(appid, existing.Name)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通知模板失败: " + err.Error()})
+		return
+	}
+	logger.Infof("更新通知模板 %s，IP: %s", existing.Name, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "更新成功"})
+}
+
+func deleteNoticeHandler(c *gin.Context) {
+	name := c.Param("name")
+	ok, err := db.DeleteNoticeTemplate(requestAppID(c), name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除通知模板失败: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "通知模板不存在"})
+		return
+	}
+	logger.Infof("删除通知模板 %s，IP: %s", name, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// previewNoticeHandler 用给定变量渲染通知模板并返回将要发送的内容，不发送消息
+func previewNoticeHandler(c *gin.Context) {
+	var body struct {
+		Vars map[string]interface{} `json:"vars"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	n := loadNotice(c)
+	if n == nil {
+		return
+	}
+
+	var req RedisTemplateMessage
+	if err := RenderNotice(n, body.Vars, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{
+		"kind":        req.Kind,
+		"template_id": req.TemplateID,
+		"url":         req.URL,
+		"page":        req.Page,
+		"miniprogram": req.MiniProgram,
+		"data":        req.Data,
+	}
+	if req.Kind != consumer.MsgTypeSubscribe {
+		if keywords, err := TemplateKeywords(c.Request.Context(), n.AppID, req.TemplateID); err == nil && keywords != nil {
+			resp["field_errors"] = vxmsg.ValidateTemplateData(keywords, dataToValidate(keywords, req.Data))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
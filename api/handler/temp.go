@@ -49,11 +49,12 @@ func PushTemplateHandler(c *gin.Context) {
 		}
 	}
 
-	// 发送消息
-	if err := vxmsg.SendTemplateMsg(msg); err != nil {
+	// 发送消息（W-AppID 为空时使用默认公众号）
+	result, err := vxmsg.SendTemplateMsgWithAppID(c.GetHeader("W-AppID"), msg)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送模板消息失败: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "消息发送成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "消息发送成功", "msgid": result.MsgID})
 }
@@ -3,21 +3,34 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"vxmsgpush/config"
 	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
 	"vxmsgpush/logger"
 )
 
+// 消息队列，模板消息、订阅通知与客服消息共用，由 consumer 按 type 分别处理
+const messageQueue = "wx_template_msg_queue"
+
 // 定义结构体用于校验 JSON 格式
 type RedisTemplateMessage struct {
+	Kind        string                 `json:"kind,omitempty" binding:"omitempty,oneof=template subscribe"` // 消息类型：template 模板消息（默认）/ subscribe 订阅通知
+	Type        string                 `json:"type"`                                                        // 队列消息类型，由 Kind 决定
+	RequestID   string                 `json:"request_id" binding:"omitempty,max=64"`                       // 为空时自动生成，可据此查询投递记录
 	Mobile      string                 `json:"mobile" binding:"required"`
-	TemplateID  string                 `json:"template_id" binding:"required"`
+	TemplateID  string                 `json:"template_id" binding:"required_without=Notice"`
 	URL         string                 `json:"url"`
-	Data        map[string]interface{} `json:"data" binding:"required"`
+	Page        string                 `json:"page,omitempty"` // 订阅通知的跳转网页
+	Data        map[string]interface{} `json:"data" binding:"required_without=Notice"`
 	MiniProgram *MiniProgram           `json:"miniprogram,omitempty"`
 	AppID       string                 `json:"appid,omitempty"`
+	Notice      string                 `json:"notice,omitempty"` // 通知模板名称，指定时由通知模板渲染 template_id 与 data
+	Vars        map[string]interface{} `json:"vars,omitempty"`   // 渲染通知模板使用的业务变量
 }
 
 // PushTemplateHandlerRedis 将校验通过的请求存入 Redis 队列
@@ -36,6 +49,37 @@ func PushTemplateHandlerRedis(c *gin.Context) {
 	if appid != "" {
 		req.AppID = appid
 	}
+	req.AppID = config.NormalizeAppID(req.AppID)
+	if req.Notice != "" {
+		if status, err := applyNotice(&req); err != nil {
+			logger.Warnf("通知模板 %s 渲染失败，IP: %s，错误: %v", req.Notice, clientIP, err)
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.Type = consumer.MsgTypeTemplate
+	if req.Kind == consumer.MsgTypeSubscribe {
+		req.Type = consumer.MsgTypeSubscribe
+	}
+	if req.RequestID == "" {
+		req.RequestID = consumer.NewRequestID()
+	}
+
+	// 模板消息按模板声明的字段校验 data，订阅通知的模板不在公众号模板列表中，不做校验
+	if req.Type == consumer.MsgTypeTemplate {
+		keywords, err := TemplateKeywords(c.Request.Context(), req.AppID, req.TemplateID)
+		if err != nil {
+			// 无法获取模板定义时不拦截，由微信接口返回最终结果
+			logger.Warnf("获取模板 %s 定义失败，跳过字段校验: %v", req.TemplateID, err)
+		} else if keywords == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "模板不存在: " + req.TemplateID})
+			return
+		} else if errs := vxmsg.ValidateTemplateData(keywords, dataToValidate(keywords, req.Data)); len(errs) > 0 {
+			logger.Warnf("模板数据校验失败，IP: %s，模板: %s，错误: %v", clientIP, req.TemplateID, errs)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "模板数据校验失败", "fields": errs})
+			return
+		}
+	}
 
 	// 原始 JSON 数据转字符串
 	jsonBytes, err := json.Marshal(req)
@@ -48,12 +92,45 @@ func PushTemplateHandlerRedis(c *gin.Context) {
 	logger.Infof("接收到推送请求，IP: %s，内容: %s", clientIP, string(jsonBytes))
 
 	// 存入 Redis list
-	err = consumer.RDB.RPush(context.Background(), "wx_template_msg_queue", jsonBytes).Err()
+	err = consumer.RDB.RPush(context.Background(), messageQueue, jsonBytes).Err()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "写入 Redis 失败: " + err.Error()})
 		return
 	}
 
-	logger.Infof("消息成功入队，IP: %s, AppID: %s", clientIP, req.AppID)
-	c.JSON(http.StatusOK, gin.H{"message": "消息入队成功"})
+	logger.Infof("消息成功入队，IP: %s, AppID: %s, RequestID: %s", clientIP, req.AppID, req.RequestID)
+	c.JSON(http.StatusOK, gin.H{"message": "消息入队成功", "request_id": req.RequestID})
+}
+
+// dataToValidate 开启规范化时，超长等可由 consumer 自动修正的内容不拦截，按规范化后的结果校验
+func dataToValidate(keywords []vxmsg.TemplateKeyword, data map[string]interface{}) map[string]interface{} {
+	if !config.Conf.Template.Normalize {
+		return data
+	}
+	normalized, _ := vxmsg.NormalizeTemplateData(keywords, data)
+	return normalized
+}
+
+// applyNotice 按名称加载通知模板并渲染到 req，返回失败时的 HTTP 状态码
+func applyNotice(req *RedisTemplateMessage) (int, error) {
+	if req.TemplateID != "" || req.Data != nil {
+		return http.StatusBadRequest, fmt.Errorf("notice 不能与 template_id、data 同时使用")
+	}
+	n, err := db.GetNoticeTemplate(req.AppID, req.Notice)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("查询通知模板失败: %v", err)
+	}
+	if n == nil || !n.Enabled {
+		return http.StatusBadRequest, fmt.Errorf("通知模板不存在或已停用: %s", req.Notice)
+	}
+	if err := RenderNotice(n, req.Vars, req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	req.Vars = nil // 已渲染为 data，不再写入队列
+	return http.StatusOK, nil
+}
+
+// requestAppID 返回 W-AppID 请求头指定的公众号，默认公众号统一为空字符串（见 config.NormalizeAppID）
+func requestAppID(c *gin.Context) string {
+	return config.NormalizeAppID(c.GetHeader("W-AppID"))
 }
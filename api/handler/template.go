@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+)
+
+// templateView 模板管理接口返回的模板
+type templateView struct {
+	TemplateID      string                  `json:"template_id"`
+	Title           string                  `json:"title"`
+	PrimaryIndustry string                  `json:"primary_industry"`
+	DeputyIndustry  string                  `json:"deputy_industry"`
+	Content         string                  `json:"content"`
+	Example         string                  `json:"example"`
+	Keywords        []vxmsg.TemplateKeyword `json:"keywords"`
+	SyncedAt        time.Time               `json:"synced_at"`
+}
+
+func newTemplateView(t *db.TemplateRecord) templateView {
+	v := templateView{
+		TemplateID:      t.TemplateID,
+		Title:           t.Title,
+		PrimaryIndustry: t.PrimaryIndustry,
+		DeputyIndustry:  t.DeputyIndustry,
+		Content:         t.Content,
+		Example:         t.Example,
+		SyncedAt:        t.SyncedAt,
+	}
+	json.Unmarshal([]byte(t.Keywords), &v.Keywords)
+	return v
+}
+
+// SyncTemplates 从微信拉取公众号的全部模板并更新 MySQL 缓存
+func SyncTemplates(ctx context.Context, appid string) ([]*db.TemplateRecord, error) {
+	list, err := vxmsg.DefaultClient(appid).GetAllPrivateTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]db.TemplateRecord, 0, len(list))
+	for _, t := range list {
+		keywords, _ := json.Marshal(vxmsg.ParseTemplateKeywords(t.Content))
+		records = append(records, db.TemplateRecord{
+			AppID:           appid,
+			TemplateID:      t.TemplateID,
+			Title:           t.Title,
+			PrimaryIndustry: t.PrimaryIndustry,
+			DeputyIndustry:  t.DeputyIndustry,
+			Content:         t.Content,
+			Example:         t.Example,
+			Keywords:        string(keywords),
+		})
+	}
+	if err := db.ReplaceTemplates(appid, records); err != nil {
+		return nil, err
+	}
+	invalidateTemplateCache(appid)
+	return db.ListTemplates(appid)
+}
+
+const (
+	templateCacheTTL   = 5 * time.Minute // 模板字段本地缓存有效期
+	templateMissResync = time.Minute     // 模板不在缓存中时，同一公众号两次从微信同步的最小间隔
+)
+
+type cachedTemplate struct {
+	keywords []vxmsg.TemplateKeyword
+	expireAt time.Time
+}
+
+var (
+	templateCache   = make(map[string]cachedTemplate) // key 为 appid + "/" + template_id
+	templateResync  = make(map[string]time.Time)      // 各公众号上次因缓存未命中而同步的时间
+	templateCacheMu sync.Mutex
+)
+
+// invalidateTemplateCache 清除公众号的模板字段本地缓存
+func invalidateTemplateCache(appid string) {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	for key := range templateCache {
+		if strings.HasPrefix(key, appid+"/") {
+			delete(templateCache, key)
+		}
+	}
+}
+
+// TemplateKeywords 返回模板声明的字段，依次查找本地缓存、MySQL 缓存，都未命中时从微信同步一次。
+// 模板不存在（包括同步受限期间缓存未命中）时返回 nil, nil。
+func TemplateKeywords(ctx context.Context, appid, templateID string) ([]vxmsg.TemplateKeyword, error) {
+	key := appid + "/" + templateID
+	templateCacheMu.Lock()
+	if t, ok := templateCache[key]; ok && time.Now().Before(t.expireAt) {
+		templateCacheMu.Unlock()
+		return t.keywords, nil
+	}
+	templateCacheMu.Unlock()
+
+	t, err := db.GetTemplate(appid, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil && allowTemplateResync(appid) {
+		if _, err := SyncTemplates(ctx, appid); err != nil {
+			return nil, err
+		}
+		if t, err = db.GetTemplate(appid, templateID); err != nil {
+			return nil, err
+		}
+	}
+	if t == nil {
+		// 同步受限期间未命中的模板同样视为不存在，保证同一请求的结果一致
+		return nil, nil
+	}
+
+	var keywords []vxmsg.TemplateKeyword
+	if err := json.Unmarshal([]byte(t.Keywords), &keywords); err != nil {
+		return nil, fmt.Errorf("解析模板字段失败: %v", err)
+	}
+	templateCacheMu.Lock()
+	templateCache[key] = cachedTemplate{keywords: keywords, expireAt: time.Now().Add(templateCacheTTL)}
+	templateCacheMu.Unlock()
+	return keywords, nil
+}
+
+// allowTemplateResync 限制缓存未命中时从微信同步的频率，避免错误的 template_id 频繁触发同步
+func allowTemplateResync(appid string) bool {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	if time.Since(templateResync[appid]) < templateMissResync {
+		return false
+	}
+	templateResync[appid] = time.Now()
+	return true
+}
+
+// RegisterTemplateAdminRoutes 注册模板管理接口，公众号通过 W-AppID 请求头指定（为空表示默认公众号）
+func RegisterTemplateAdminRoutes(rg *gin.RouterGroup) {
+	rg.GET("/list", listTemplatesHandler)
+	rg.POST("/sync", syncTemplatesHandler)
+	rg.POST("/add", addTemplateHandler)
+	rg.DELETE("/:template_id", deleteTemplateHandler)
+	rg.GET("/industry", getIndustryHandler)
+	rg.POST("/industry", setIndustryHandler)
+}
+
+func respondTemplates(c *gin.Context, list []*db.TemplateRecord) {
+	views := make([]templateView, 0, len(list))
+	for _, t := range list {
+		views = append(views, newTemplateView(t))
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": views})
+}
+
+// listTemplatesHandler 返回缓存的模板，缓存为空时先从微信同步
+func listTemplatesHandler(c *gin.Context) {
+	appid := requestAppID(c)
+	list, err := db.ListTemplates(appid)
+	if err == nil && len(list) == 0 {
+		list, err = SyncTemplates(c.Request.Context(), appid)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询模板失败: " + err.Error()})
+		return
+	}
+	respondTemplates(c, list)
+}
+
+func syncTemplatesHandler(c *gin.Context) {
+	list, err := SyncTemplates(c.Request.Context(), requestAppID(c))
+	if err != nil {
+		logger.Errorf("同步模板失败，IP: %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "同步模板失败: " + err.Error()})
+		return
+	}
+	respondTemplates(c, list)
+}
+
+type addTemplateRequest struct {
+	TemplateIDShort string   `json:"template_id_short" binding:"required"`
+	KeywordNameList []string `json:"keyword_name_list"`
+}
+
+func addTemplateHandler(c *gin.Context) {
+	var req addTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+
+	appid := requestAppID(c)
+	templateID, err := vxmsg.DefaultClient(appid).AddTemplate(c.Request.Context(), req.TemplateIDShort, req.KeywordNameList)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加模板失败: " + err.Error()})
+		return
+	}
+	logger.Infof("添加模板 %s，IP: %s", templateID, c.ClientIP())
+
+	if _, err := SyncTemplates(c.Request.Context(), appid); err != nil {
+		logger.Warnf("添加模板后同步模板缓存失败: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "添加成功", "template_id": templateID})
+}
+
+func deleteTemplateHandler(c *gin.Context) {
+	appid := requestAppID(c)
+	templateID := c.Param("template_id")
+	if err := vxmsg.DefaultClient(appid).DeletePrivateTemplate(c.Request.Context(), templateID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除模板失败: " + err.Error()})
+		return
+	}
+	logger.Infof("删除模板 %s，IP: %s", templateID, c.ClientIP())
+
+	if err := db.DeleteTemplate(appid, templateID); err != nil {
+		logger.Warnf("删除模板缓存失败: %v", err)
+	}
+	invalidateTemplateCache(appid)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func getIndustryHandler(c *gin.Context) {
+	industry, err := vxmsg.DefaultClient(requestAppID(c)).GetIndustry(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取所属行业失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, industry)
+}
+
+type setIndustryRequest struct {
+	IndustryID1 string `json:"industry_id1" binding:"required"`
+	IndustryID2 string `json:"industry_id2" binding:"required"`
+}
+
+func setIndustryHandler(c *gin.Context) {
+	var req setIndustryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误: " + err.Error()})
+		return
+	}
+	if err := vxmsg.DefaultClient(requestAppID(c)).SetIndustry(c.Request.Context(), req.IndustryID1, req.IndustryID2); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置所属行业失败: " + err.Error()})
+		return
+	}
+	logger.Infof("设置所属行业 %s / %s，IP: %s", req.IndustryID1, req.IndustryID2, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功"})
+}
@@ -4,6 +4,7 @@ import (
 	"vxmsgpush/api/handler"
 	"vxmsgpush/api/whitelist"
 	"vxmsgpush/config"
+	"vxmsgpush/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,15 +25,32 @@ func SetupRouter() *gin.Engine {
 	outGroup := r.Group("/out", whitelist.AllowOutSystem(config.Conf.Security.AllowedIPs...))
 	{
 		outGroup.POST("/template", handler.PushTemplateHandlerRedis)
+		outGroup.GET("/message/:request_id", handler.GetMessageRecordHandler)
+		outGroup.POST("/custom", handler.PushCustomHandler)
+		outGroup.POST("/custom/typing", handler.PushTypingHandler)
 	}
 
 	// WeChat 路由组
 	{
-		wechatServer := handler.NewWechatServer("SmileSion")
+		wechatServer, err := handler.NewWechatServerFromConfig(config.Conf.Callback)
+		if err != nil {
+			logger.Fatalf("微信回调配置错误: %v", err)
+		}
+		autoReply := handler.NewAutoReply(wechatServer.AppID)
+		autoReply.Register(wechatServer)
+
 		wechatGroup := r.Group("/wechat")
 		{
 			wechatServer.RegisterRoutes(wechatGroup)
 		}
+
+		// 管理接口，与 /out 使用相同的 IP 白名单
+		adminGroup := r.Group("/admin", whitelist.AllowOutSystem(config.Conf.Security.AllowedIPs...))
+		{
+			autoReply.RegisterAdminRoutes(adminGroup.Group("/autoreply"))
+			handler.RegisterTemplateAdminRoutes(adminGroup.Group("/template"))
+			handler.RegisterNoticeAdminRoutes(adminGroup.Group("/notice"))
+		}
 	}
 
 	// push 路由组（含中间件）
@@ -14,6 +14,7 @@ const (
 	dispatcherCount = 10  // dispatcher并发BRPop（根据CPU核数调整）
     workerCount = 50     // worker并发处理（根据业务耗时调整）
     chanBuffer = 2000     // chan缓冲大小，防止瞬时阻塞
+	webhookWorkerCount = 5 // 回调转发 worker 数量
 )
 
 func main() {
@@ -45,6 +46,7 @@ func main() {
 	consumer.StartStatWriter()
 	consumer.StartRedisConsumers(rdb, mainQueue, dispatcherCount,workerCount,chanBuffer)
 	consumer.StartRetryScheduler(rdb, delayQueue, mainQueue,30)
+	consumer.StartWebhookWorkers(rdb, webhookWorkerCount)
 
 	// 初始化 Gin 路由
 	r := api.SetupRouter()
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// token 获取方式
+const (
+	modeToken  = "token"  // cgi-bin/token，每次获取都会使之前的 token 失效
+	modeStable = "stable" // cgi-bin/stable_token，可与其他平台共享 token 而互不影响
+)
+
+// appConfig 单个公众号的 appid/secret 配置
+type appConfig struct {
+	AppID     string `toml:"appid"`
+	AppSecret string `toml:"appsecret"`
+	Mode      string `toml:"mode"` // token（默认）| stable
+}
+
+// appsFile 多公众号配置文件结构
+type appsFile struct {
+	DefaultAppID string      `toml:"default_appid"`
+	Apps         []appConfig `toml:"app"`
+}
+
+// loadApps 加载公众号配置：优先读取 WX_APPS_FILE 指定的文件，否则回退到 WX_APPID/WX_APPSECRET
+// 返回配置列表以及默认 AppID（请求未携带 appid 时使用）
+func loadApps() ([]appConfig, string, error) {
+	if path := os.Getenv("WX_APPS_FILE"); path != "" {
+		var f appsFile
+		if _, err := toml.DecodeFile(path, &f); err != nil {
+			return nil, "", fmt.Errorf("读取公众号配置文件 %s 失败: %v", path, err)
+		}
+		if len(f.Apps) == 0 {
+			return nil, "", fmt.Errorf("公众号配置文件 %s 中没有配置任何 [[app]]", path)
+		}
+
+		seen := make(map[string]struct{}, len(f.Apps))
+		for i := range f.Apps {
+			app := &f.Apps[i]
+			if app.AppID == "" || app.AppSecret == "" {
+				return nil, "", fmt.Errorf("公众号配置不完整: appid=%q", app.AppID)
+			}
+			if err := normalizeMode(app); err != nil {
+				return nil, "", err
+			}
+			if _, ok := seen[app.AppID]; ok {
+				return nil, "", fmt.Errorf("公众号 appid 重复: %s", app.AppID)
+			}
+			seen[app.AppID] = struct{}{}
+		}
+
+		defaultAppID := f.DefaultAppID
+		if defaultAppID == "" {
+			defaultAppID = f.Apps[0].AppID
+		}
+		if _, ok := seen[defaultAppID]; !ok {
+			return nil, "", fmt.Errorf("默认公众号 %s 不在配置列表中", defaultAppID)
+		}
+		return f.Apps, defaultAppID, nil
+	}
+
+	appID := os.Getenv("WX_APPID")
+	appSecret := os.Getenv("WX_APPSECRET")
+	if appID == "" || appSecret == "" {
+		return nil, "", fmt.Errorf("WX_APPS_FILE 与 WX_APPID/WX_APPSECRET 均未配置")
+	}
+	app := appConfig{AppID: appID, AppSecret: appSecret, Mode: os.Getenv("WX_TOKEN_MODE")}
+	if err := normalizeMode(&app); err != nil {
+		return nil, "", err
+	}
+	return []appConfig{app}, appID, nil
+}
+
+// normalizeMode 校验 token 获取方式，未配置时使用 cgi-bin/token
+func normalizeMode(app *appConfig) error {
+	switch app.Mode {
+	case "":
+		app.Mode = modeToken
+	case modeToken, modeStable:
+	default:
+		return fmt.Errorf("公众号 %s 的 mode 配置错误: %s（可选 token/stable）", app.AppID, app.Mode)
+	}
+	return nil
+}
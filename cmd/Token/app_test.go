@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "vxmsgpush/core/grpc/token"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadAppsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apps.toml")
+	content := `default_appid = "wx-b"
+
+[[app]]
+appid = "wx-a"
+appsecret = "secret-a"
+
+[[app]]
+appid = "wx-b"
+appsecret = "secret-b"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	t.Setenv("WX_APPS_FILE", path)
+
+	apps, defaultAppID, err := loadApps()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if len(apps) != 2 || apps[0].AppID != "wx-a" || apps[1].AppSecret != "secret-b" {
+		t.Errorf("公众号配置解析错误: %+v", apps)
+	}
+	if defaultAppID != "wx-b" {
+		t.Errorf("默认公众号应为 wx-b，实际 %s", defaultAppID)
+	}
+
+	// 默认公众号不在列表中、appid 重复均应报错
+	for _, bad := range []string{
+		"default_appid = \"wx-c\"\n[[app]]\nappid = \"wx-a\"\nappsecret = \"s\"\n",
+		"[[app]]\nappid = \"wx-a\"\nappsecret = \"s\"\n[[app]]\nappid = \"wx-a\"\nappsecret = \"s\"\n",
+		"[[app]]\nappid = \"wx-a\"\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatalf("写入配置文件失败: %v", err)
+		}
+		if _, _, err := loadApps(); err == nil {
+			t.Errorf("配置应被拒绝:\n%s", bad)
+		}
+	}
+}
+
+func TestLoadAppsFromEnv(t *testing.T) {
+	t.Setenv("WX_APPS_FILE", "")
+	t.Setenv("WX_APPID", "wx-env")
+	t.Setenv("WX_APPSECRET", "secret")
+
+	apps, defaultAppID, err := loadApps()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if len(apps) != 1 || apps[0].AppID != "wx-env" || defaultAppID != "wx-env" {
+		t.Errorf("应回退到 WX_APPID/WX_APPSECRET: %+v %s", apps, defaultAppID)
+	}
+
+	t.Setenv("WX_APPSECRET", "")
+	if _, _, err := loadApps(); err == nil {
+		t.Errorf("缺少 WX_APPSECRET 时应返回错误")
+	}
+}
+
+func TestServerRoutesByAppID(t *testing.T) {
+	s := &server{managers: make(map[string]*tokenManager), defaultAppID: "wx-a"}
+	for _, appID := range []string{"wx-a", "wx-b"} {
+		m := newTokenManager(appConfig{AppID: appID, AppSecret: "secret"}, nil, log.New(io.Discard, "", 0))
+		m.token, m.expireAt = "token-"+appID, time.Now().Add(time.Hour)
+		s.managers[appID] = m
+	}
+
+	for appID, want := range map[string]string{"": "token-wx-a", "wx-a": "token-wx-a", "wx-b": "token-wx-b"} {
+		reply, err := s.GetAccessToken(context.Background(), &pb.TokenRequest{Appid: appID})
+		if err != nil {
+			t.Errorf("appid %q 获取 token 失败: %v", appID, err)
+			continue
+		}
+		if reply.AccessToken != want {
+			t.Errorf("appid %q 期望 %s，实际 %s", appID, want, reply.AccessToken)
+		}
+	}
+
+	if _, err := s.GetAccessToken(context.Background(), &pb.TokenRequest{Appid: "wx-unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("未配置的 appid 应返回 NotFound，实际 %v", err)
+	}
+}
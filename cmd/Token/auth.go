@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 客户端认证使用的 metadata key
+const (
+	mdClientID  = "x-client-id"
+	mdClientKey = "x-client-key"
+)
+
+// serverCredentials 根据 TOKEN_TLS_* 环境变量构造 TLS 凭证：
+// 配置 TOKEN_TLS_CERT/TOKEN_TLS_KEY 启用 TLS，额外配置 TOKEN_TLS_CLIENT_CA 时要求客户端证书（mTLS）。
+// 未配置证书时返回 nil，使用明文连接。
+func serverCredentials() (credentials.TransportCredentials, error) {
+	certFile := os.Getenv("TOKEN_TLS_CERT")
+	keyFile := os.Getenv("TOKEN_TLS_KEY")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TOKEN_TLS_CERT 与 TOKEN_TLS_KEY 需同时配置")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端证书失败: %v", err)
+	}
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := os.Getenv("TOKEN_TLS_CLIENT_CA"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("客户端 CA 文件 %s 中没有有效证书", caFile)
+		}
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConf), nil
+}
+
+// authenticator 校验客户端通过 metadata 携带的 client id / key。
+// TOKEN_AUTH_SECRET 为所有客户端共用的密钥，TOKEN_AUTH_KEYS 为按客户端分配的密钥（格式 id1:key1,id2:key2），
+// 两者都未配置时不校验。
+type authenticator struct {
+	sharedSecret string
+	clientKeys   map[string]string
+	logger       *log.Logger
+}
+
+func newAuthenticator(logger *log.Logger) (*authenticator, error) {
+	a := &authenticator{
+		sharedSecret: os.Getenv("TOKEN_AUTH_SECRET"),
+		clientKeys:   make(map[string]string),
+		logger:       logger,
+	}
+	if v := os.Getenv("TOKEN_AUTH_KEYS"); v != "" {
+		for _, pair := range strings.Split(v, ",") {
+			id, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || id == "" || key == "" {
+				return nil, fmt.Errorf("TOKEN_AUTH_KEYS 格式错误: %q", pair)
+			}
+			a.clientKeys[id] = key
+		}
+	}
+	return a, nil
+}
+
+// enabled 是否启用了客户端认证
+func (a *authenticator) enabled() bool {
+	return a.sharedSecret != "" || len(a.clientKeys) > 0
+}
+
+// check 校验请求 metadata，健康检查接口不需要认证以便探针访问
+func (a *authenticator) check(ctx context.Context, fullMethod string) error {
+	if !a.enabled() || strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	clientID := firstValue(md, mdClientID)
+	clientKey := firstValue(md, mdClientKey)
+	if clientKey == "" {
+		return status.Error(codes.Unauthenticated, "缺少客户端凭证")
+	}
+
+	if key, ok := a.clientKeys[clientID]; ok && subtle.ConstantTimeCompare([]byte(key), []byte(clientKey)) == 1 {
+		return nil
+	}
+	if a.sharedSecret != "" && subtle.ConstantTimeCompare([]byte(a.sharedSecret), []byte(clientKey)) == 1 {
+		return nil
+	}
+
+	a.logger.Printf("[auth] 客户端认证失败，client_id=%q，方法 %s", clientID, fullMethod)
+	return status.Error(codes.Unauthenticated, "客户端凭证无效")
+}
+
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
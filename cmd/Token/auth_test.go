@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	t.Setenv("TOKEN_AUTH_SECRET", "shared")
+	t.Setenv("TOKEN_AUTH_KEYS", "push:push-key, admin:admin-key")
+	auth, err := newAuthenticator(log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("创建认证器失败: %v", err)
+	}
+
+	call := func(method string, kv ...string) codes.Code {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+		_, err := auth.unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil })
+		return status.Code(err)
+	}
+	const method = "/token.TokenService/GetAccessToken"
+
+	cases := []struct {
+		name string
+		kv   []string
+		want codes.Code
+	}{
+		{"缺少密钥", nil, codes.Unauthenticated},
+		{"只有 client id", []string{mdClientID, "push"}, codes.Unauthenticated},
+		{"密钥错误", []string{mdClientID, "push", mdClientKey, "wrong"}, codes.Unauthenticated},
+		{"使用其他客户端的密钥", []string{mdClientID, "push", mdClientKey, "admin-key"}, codes.Unauthenticated},
+		{"客户端密钥正确", []string{mdClientID, "push", mdClientKey, "push-key"}, codes.OK},
+		{"共享密钥正确", []string{mdClientKey, "shared"}, codes.OK},
+	}
+	for _, c := range cases {
+		if got := call(method, c.kv...); got != c.want {
+			t.Errorf("%s: 期望 %v，实际 %v", c.name, c.want, got)
+		}
+	}
+
+	// 健康检查不需要认证
+	if got := call("/grpc.health.v1.Health/Check"); got != codes.OK {
+		t.Errorf("健康检查不应要求认证，实际 %v", got)
+	}
+
+	// 流式接口同样校验
+	stream := &authTestStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(mdClientKey, "wrong"))}
+	err = auth.streamInterceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/token.TokenService/WatchAccessToken"},
+		func(srv interface{}, ss grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("流式接口密钥错误应拒绝，实际 %v", err)
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	t.Setenv("TOKEN_AUTH_SECRET", "")
+	t.Setenv("TOKEN_AUTH_KEYS", "")
+	auth, err := newAuthenticator(log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("创建认证器失败: %v", err)
+	}
+	if auth.enabled() {
+		t.Fatalf("未配置密钥时不应启用认证")
+	}
+	if err := auth.check(context.Background(), "/token.TokenService/GetAccessToken"); err != nil {
+		t.Errorf("未启用认证时应放行: %v", err)
+	}
+
+	t.Setenv("TOKEN_AUTH_KEYS", "push")
+	if _, err := newAuthenticator(log.New(io.Discard, "", 0)); err == nil {
+		t.Errorf("TOKEN_AUTH_KEYS 格式错误时应返回错误")
+	}
+}
+
+// authTestStream 只提供 Context 的 grpc.ServerStream
+type authTestStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authTestStream) Context() context.Context { return s.ctx }
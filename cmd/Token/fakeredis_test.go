@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis 仅实现 tokenStore 用到的命令的内存 Redis（RESP2），用于测试主从切换
+type fakeRedis struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	strs    map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+	subs    map[string][]*fakeRedisConn
+}
+
+type fakeRedisConn struct {
+	net.Conn
+	wmu sync.Mutex
+}
+
+func (c *fakeRedisConn) reply(s string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	io.WriteString(c.Conn, s)
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	f := &fakeRedis{
+		ln:      ln,
+		strs:    make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+		subs:    make(map[string][]*fakeRedisConn),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(&fakeRedisConn{Conn: conn})
+		}
+	}()
+	return f
+}
+
+// newStore 创建连接到 fakeRedis 的 tokenStore
+func (f *fakeRedis) newStore(t *testing.T, id string) *tokenStore {
+	rdb := redis.NewClient(&redis.Options{Addr: f.ln.Addr().String()})
+	t.Cleanup(func() { rdb.Close() })
+	return &tokenStore{rdb: rdb, id: id, logger: log.New(io.Discard, "", 0)}
+}
+
+// del 删除 key，模拟锁过期
+func (f *fakeRedis) del(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.strs, key)
+	delete(f.hashes, key)
+	delete(f.expires, key)
+}
+
+func (f *fakeRedis) serve(c *fakeRedisConn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "MULTI":
+			inMulti, queued = true, nil
+			c.reply("+OK\r\n")
+		case cmd == "EXEC":
+			out := fmt.Sprintf("*%d\r\n", len(queued))
+			for _, q := range queued {
+				out += f.exec(c, q)
+			}
+			inMulti = false
+			c.reply(out)
+		case inMulti:
+			queued = append(queued, args)
+			c.reply("+QUEUED\r\n")
+		default:
+			if reply := f.exec(c, args); reply != "" {
+				c.reply(reply)
+			}
+		}
+	}
+}
+
+// exec 执行单条命令并返回 RESP 响应
+func (f *fakeRedis) exec(c *fakeRedisConn, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purgeExpired()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		v, ok := f.strs[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		// SET key value [NX] [PX ms]
+		key, nx := args[1], false
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			case "EX":
+				sec, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(sec) * time.Second
+				i++
+			}
+		}
+		if _, exists := f.strs[key]; nx && exists {
+			return "$-1\r\n"
+		}
+		f.strs[key] = args[2]
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "HSET":
+		h := f.hashes[args[1]]
+		if h == nil {
+			h = make(map[string]string)
+			f.hashes[args[1]] = h
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			h[args[i]] = args[i+1]
+		}
+		return fmt.Sprintf(":%d\r\n", (len(args)-2)/2)
+	case "HGETALL":
+		h := f.hashes[args[1]]
+		out := fmt.Sprintf("*%d\r\n", len(h)*2)
+		for k, v := range h {
+			out += bulk(k) + bulk(v)
+		}
+		return out
+	case "EXPIREAT":
+		ts, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expires[args[1]] = time.Unix(ts, 0)
+		return ":1\r\n"
+	case "EVALSHA":
+		return "-NOSCRIPT No matching script\r\n"
+	case "EVAL":
+		// 仅支持 renewLockScript：EVAL script 1 key id ttl_ms
+		key, id := args[3], args[4]
+		if f.strs[key] != id {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		f.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "PUBLISH":
+		subs := f.subs[args[1]]
+		msg := "*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2])
+		for _, s := range subs {
+			go s.reply(msg)
+		}
+		return fmt.Sprintf(":%d\r\n", len(subs))
+	case "SUBSCRIBE":
+		out := ""
+		for i, ch := range args[1:] {
+			f.subs[ch] = append(f.subs[ch], c)
+			out += "*3\r\n" + bulk("subscribe") + bulk(ch) + fmt.Sprintf(":%d\r\n", i+1)
+		}
+		return out
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) purgeExpired() {
+	now := time.Now()
+	for key, at := range f.expires {
+		if !now.Before(at) {
+			delete(f.strs, key)
+			delete(f.hashes, key)
+			delete(f.expires, key)
+		}
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand 读取一条 RESP 数组命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("不支持的请求: %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		head, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(head[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWechatBaseURL = "http://192.170.144.52:9010/weixin_api"
+
+var (
+	wechatBaseURL = defaultWechatBaseURL
+	wechatClient  = &http.Client{Timeout: 10 * time.Second}
+)
+
+// initWechatGateway 根据 WX_BASE_URL / WX_TIMEOUT / WX_PROXY 初始化微信接口前缀与 HTTP 客户端
+func initWechatGateway() error {
+	if v := os.Getenv("WX_BASE_URL"); v != "" {
+		wechatBaseURL = strings.TrimRight(v, "/")
+	}
+
+	timeout := 10 * time.Second
+	if v := os.Getenv("WX_TIMEOUT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("WX_TIMEOUT 配置错误: %q", v)
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if v := os.Getenv("WX_PROXY"); v != "" {
+		proxyURL, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("WX_PROXY 配置错误: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	wechatClient = &http.Client{Timeout: timeout, Transport: transport}
+	return nil
+}
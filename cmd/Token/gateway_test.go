@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInitWechatGateway(t *testing.T) {
+	oldURL, oldClient := wechatBaseURL, wechatClient
+	t.Cleanup(func() { wechatBaseURL, wechatClient = oldURL, oldClient })
+
+	t.Setenv("WX_BASE_URL", "https://api.weixin.qq.com/")
+	t.Setenv("WX_TIMEOUT", "3")
+	t.Setenv("WX_PROXY", "http://127.0.0.1:3128")
+	if err := initWechatGateway(); err != nil {
+		t.Fatalf("初始化失败: %v", err)
+	}
+	if wechatBaseURL != "https://api.weixin.qq.com" {
+		t.Errorf("应去掉末尾的 /，实际 %s", wechatBaseURL)
+	}
+	if wechatClient.Timeout != 3*time.Second {
+		t.Errorf("超时应为 3s，实际 %v", wechatClient.Timeout)
+	}
+	req, _ := http.NewRequest(http.MethodGet, wechatBaseURL+"/cgi-bin/token", nil)
+	proxy, err := wechatClient.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "127.0.0.1:3128" {
+		t.Errorf("代理配置未生效: %v %v", proxy, err)
+	}
+
+	for _, timeout := range []string{"abc", "0", "-1"} {
+		t.Setenv("WX_TIMEOUT", timeout)
+		if err := initWechatGateway(); err == nil {
+			t.Errorf("WX_TIMEOUT=%q 应返回错误", timeout)
+		}
+	}
+}
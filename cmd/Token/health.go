@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const tokenServiceName = "token.TokenService"
+
+// Prometheus 指标
+var (
+	refreshCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_refresh_total",
+		Help: "Total number of successful access_token refreshes from WeChat",
+	}, []string{"appid"})
+	refreshFailCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_refresh_fail_total",
+		Help: "Total number of failed access_token refreshes from WeChat",
+	}, []string{"appid"})
+	expireSecondsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "token_expire_seconds",
+		Help: "Seconds until the cached access_token expires",
+	}, []string{"appid"})
+	upstreamLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "token_upstream_latency_seconds",
+		Help:    "Latency of WeChat token endpoint requests",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"appid"})
+)
+
+func init() {
+	prometheus.MustRegister(refreshCounter)
+	prometheus.MustRegister(refreshFailCounter)
+	prometheus.MustRegister(expireSecondsGauge)
+	prometheus.MustRegister(upstreamLatency)
+}
+
+// healthReporter 根据各公众号 token 状态更新 gRPC 健康检查与就绪状态
+type healthReporter struct {
+	hs       *health.Server
+	managers map[string]*tokenManager
+	ready    atomic.Bool
+}
+
+func newHealthReporter(managers map[string]*tokenManager) *healthReporter {
+	h := &healthReporter{hs: health.NewServer(), managers: managers}
+	h.update()
+	return h
+}
+
+// update 刷新健康状态：服务整体在所有公众号都持有有效 token 时为 SERVING，
+// 每个公众号另以 appid 作为 service 名单独上报
+func (h *healthReporter) update() {
+	allValid := true
+	for appID, m := range h.managers {
+		token, expireAt := m.get()
+		remaining := time.Until(expireAt)
+		valid := token != "" && remaining > 0
+		if !valid {
+			allValid = false
+			remaining = 0
+		}
+		expireSecondsGauge.WithLabelValues(appID).Set(remaining.Seconds())
+		h.hs.SetServingStatus(appID, servingStatus(valid))
+	}
+
+	h.hs.SetServingStatus("", servingStatus(allValid))
+	h.hs.SetServingStatus(tokenServiceName, servingStatus(allValid))
+	h.ready.Store(allValid)
+}
+
+// run 每秒更新一次健康状态
+func (h *healthReporter) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.update()
+	}
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// serveHTTP 在 TOKEN_METRICS_ADDR（默认 :51002）暴露 /metrics、/healthz、/readyz
+func (h *healthReporter) serveHTTP(logger *log.Logger) {
+	addr := os.Getenv("TOKEN_METRICS_ADDR")
+	if addr == "" {
+		addr = ":51002"
+	}
+
+	logger.Printf("TokenService 监控接口启动，监听 %s", addr)
+	if err := http.ListenAndServe(addr, h.handler()); err != nil {
+		logger.Printf("监控接口启动失败: %v", err)
+	}
+}
+
+// handler 返回 /metrics、/healthz、/readyz 路由
+func (h *healthReporter) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !h.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("access_token not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "vxmsgpush/core/grpc/token"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthReporter(t *testing.T) {
+	upstream := &fakeWechat{}
+	useFakeWechat(t, upstream)
+	m := newTestManager(nil)
+	s := &server{managers: map[string]*tokenManager{"wx-test": m}, defaultAppID: "wx-test"}
+	h := newHealthReporter(s.managers)
+	srv := httptest.NewServer(h.handler())
+	defer srv.Close()
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := h.hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("健康检查 %q 失败: %v", service, err)
+		}
+		return resp.Status
+	}
+	get := func(path string) (int, string) {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("请求 %s 失败: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// 尚未获取到 token：NOT_SERVING、未就绪，GetAccessToken 返回 Unavailable
+	for _, service := range []string{"", tokenServiceName, "wx-test"} {
+		if got := check(service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("无 token 时 %q 应为 NOT_SERVING，实际 %v", service, got)
+		}
+	}
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("无 token 时 /readyz 应返回 503，实际 %d", code)
+	}
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz 应始终返回 200，实际 %d", code)
+	}
+	if _, err := s.GetAccessToken(context.Background(), &pb.TokenRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("无 token 时应返回 Unavailable，实际 %v", err)
+	}
+
+	if _, _, err := m.refresh("", false); err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	h.update()
+
+	for _, service := range []string{"", tokenServiceName, "wx-test"} {
+		if got := check(service); got != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("获取 token 后 %q 应为 SERVING，实际 %v", service, got)
+		}
+	}
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Errorf("获取 token 后 /readyz 应返回 200，实际 %d", code)
+	}
+	if _, body := get("/metrics"); !strings.Contains(body, `token_expire_seconds{appid="wx-test"}`) ||
+		!strings.Contains(body, `token_refresh_total{appid="wx-test"}`) {
+		t.Errorf("/metrics 缺少 token 指标")
+	}
+}
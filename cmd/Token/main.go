@@ -2,94 +2,101 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net"
-	"net/http"
 	"os"
-	"sync"
 	"time"
 
 	pb "vxmsgpush/core/grpc/token"
 
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var (
-	token    string
-	expireAt time.Time
-	mu       sync.Mutex
-)
-
-// 微信接口返回结构
-type tokenResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int64  `json:"expires_in"`
-	ErrCode     int    `json:"errcode"`
-	ErrMsg      string `json:"errmsg"`
-}
-
 type server struct {
 	pb.UnimplementedTokenServiceServer
+	managers     map[string]*tokenManager
+	defaultAppID string
+}
+
+// manager 根据请求中的 appid 查找对应的 tokenManager，为空时使用默认公众号
+func (s *server) manager(appID string) (*tokenManager, error) {
+	if appID == "" {
+		appID = s.defaultAppID
+	}
+	m, ok := s.managers[appID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "未配置的公众号 appid: %s", appID)
+	}
+	return m, nil
 }
 
 // gRPC 方法：返回缓存的 AccessToken
 func (s *server) GetAccessToken(ctx context.Context, req *pb.TokenRequest) (*pb.TokenReply, error) {
-	mu.Lock()
-	defer mu.Unlock()
+	m, err := s.manager(req.GetAppid())
+	if err != nil {
+		return nil, err
+	}
+	token, expireAt := m.get()
+	if token == "" || !time.Now().Before(expireAt) {
+		return nil, status.Errorf(codes.Unavailable, "公众号 %s 暂无有效的 access_token", m.app.AppID)
+	}
 	return &pb.TokenReply{
 		AccessToken: token,
 		ExpireAt:    expireAt.Unix(),
+		Appid:       m.app.AppID,
 	}, nil
 }
 
-// 定时获取 token
-func fetchTokenLoop(appID, appSecret string, logger *log.Logger) {
-	for {
-		//本地测试用
-		// url := fmt.Sprintf("http://127.0.0.1:9011/weixin_api/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s", appID, appSecret)
-
-		// 生产环境用
-		url := fmt.Sprintf("http://192.170.144.52:9010/weixin_api/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s", appID, appSecret)
-
-		resp, err := http.Get(url)
-		if err != nil {
-			logger.Printf("请求微信接口失败: %v", err)
-			time.Sleep(10 * time.Second)
-			continue
-		}
+// gRPC 方法：强制刷新 AccessToken
+func (s *server) RefreshAccessToken(ctx context.Context, req *pb.RefreshRequest) (*pb.TokenReply, error) {
+	m, err := s.manager(req.GetAppid())
+	if err != nil {
+		return nil, err
+	}
+	token, expireAt, err := m.refresh(req.GetStaleToken(), true)
+	if err != nil {
+		m.logger.Printf("[%s] 强制刷新 access_token 失败: %v", m.app.AppID, err)
+		return nil, status.Errorf(codes.Unavailable, "刷新 access_token 失败: %v", err)
+	}
+	return &pb.TokenReply{
+		AccessToken: token,
+		ExpireAt:    expireAt.Unix(),
+		Appid:       m.app.AppID,
+	}, nil
+}
 
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
+// gRPC 方法：推送 AccessToken，客户端据此维护本地缓存
+func (s *server) WatchAccessToken(req *pb.TokenRequest, stream grpc.ServerStreamingServer[pb.TokenReply]) error {
+	m, err := s.manager(req.GetAppid())
+	if err != nil {
+		return err
+	}
+	updates, cancel := m.subscribe()
+	defer cancel()
 
-		var result tokenResponse
-		if err := json.Unmarshal(body, &result); err != nil {
-			logger.Printf("解析响应失败: %v, 原始响应: %s", err, string(body))
-			time.Sleep(10 * time.Second)
-			continue
+	for {
+		token, expireAt := m.get()
+		if token != "" {
+			if err := stream.Send(&pb.TokenReply{
+				AccessToken: token,
+				ExpireAt:    expireAt.Unix(),
+				Appid:       m.app.AppID,
+			}); err != nil {
+				return err
+			}
 		}
 
-		if result.ErrCode != 0 {
-			logger.Printf("微信返回错误: %s，原始响应: %s", result.ErrMsg, string(body))
-			time.Sleep(10 * time.Second)
-			continue
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-updates:
 		}
-
-		mu.Lock()
-		token = result.AccessToken
-		expireAt = time.Now().Add(time.Duration(result.ExpiresIn-100) * time.Second)
-		mu.Unlock()
-		logger.Printf("成功刷新 access_token: %s，有效期 %ds", result.AccessToken, result.ExpiresIn)
-		logger.Printf("成功刷新 access_token，有效期 %ds", result.ExpiresIn)
-
-		// 休眠到过期前 1 分钟
-		sleepDuration := time.Duration(result.ExpiresIn-60) * time.Second
-		time.Sleep(sleepDuration)
 	}
 }
 
@@ -108,15 +115,65 @@ func main() {
 	if err != nil {
 		log.Fatalf("加载 .env 文件失败: %v", err)
 	}
-	// 微信参数（写死，也可以改成读取配置）
-	appID := os.Getenv("WX_APPID")
-	appSecret := os.Getenv("WX_APPSECRET")
-	if appID == "" || appSecret == "" {
-		log.Fatal("WX_APPID 或 WX_APPSECRET 未配置")
+	// 微信接口网关：WX_BASE_URL 可指向测试环境、mock 服务或 https://api.weixin.qq.com
+	if err := initWechatGateway(); err != nil {
+		log.Fatalf("微信接口配置错误: %v", err)
+	}
+	logger.Printf("微信接口地址: %s", wechatBaseURL)
+
+	// 微信参数：WX_APPS_FILE 指定多公众号配置，未配置时读取 WX_APPID/WX_APPSECRET
+	apps, defaultAppID, err := loadApps()
+	if err != nil {
+		log.Fatalf("加载公众号配置失败: %v", err)
 	}
 
-	// 启动定时刷新协程
-	go fetchTokenLoop(appID, appSecret, logger)
+	// 配置 TOKEN_REDIS_ADDR 时持久化 token 并进行主节点选举
+	store, err := newTokenStore(logger)
+	if err != nil {
+		log.Fatalf("初始化 token 存储失败: %v", err)
+	}
+	if store != nil {
+		// 先同步选举一次，避免启动后首个刷新周期空等
+		store.elect()
+		go store.runElection()
+	}
+
+	s := &server{
+		managers:     make(map[string]*tokenManager, len(apps)),
+		defaultAppID: defaultAppID,
+	}
+	// 每个公众号启动一个定时刷新协程
+	for _, app := range apps {
+		m := newTokenManager(app, store, logger)
+		s.managers[app.AppID] = m
+		go m.loop()
+	}
+	logger.Printf("已加载 %d 个公众号，默认公众号: %s", len(apps), defaultAppID)
+
+	if store != nil {
+		go store.watchRefreshRequests(func(appID, stale string) {
+			m, ok := s.managers[appID]
+			if !ok {
+				return
+			}
+			go func() {
+				if err := m.handleRefreshRequest(stale); err != nil {
+					logger.Printf("[%s] 处理从节点刷新请求失败: %v", appID, err)
+				}
+			}()
+		}, func(appID, typ string) {
+			m, ok := s.managers[appID]
+			if !ok {
+				return
+			}
+			go func() {
+				if _, _, err := m.ticket(typ); err != nil {
+					logger.Printf("[%s] 处理从节点 %s 票据请求失败: %v", appID, typ, err)
+				}
+			}()
+		})
+		logger.Println("已启用 Redis 持久化 access_token")
+	}
 
 	// 启动 gRPC 服务
 	lis, err := net.Listen("tcp", ":51001")
@@ -124,12 +181,39 @@ func main() {
 		logger.Fatalf("监听端口失败: %v", err)
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterTokenServiceServer(s, &server{})
-	reflection.Register(s)
+	// 健康检查：在获取到有效 token 之前上报 NOT_SERVING
+	hr := newHealthReporter(s.managers)
+	go hr.run()
+	go hr.serveHTTP(logger)
+
+	// 传输层 TLS/mTLS 与客户端密钥认证（均为可选）
+	creds, err := serverCredentials()
+	if err != nil {
+		logger.Fatalf("初始化 TLS 失败: %v", err)
+	}
+	auth, err := newAuthenticator(logger)
+	if err != nil {
+		logger.Fatalf("初始化客户端认证失败: %v", err)
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(auth.unaryInterceptor),
+		grpc.ChainStreamInterceptor(auth.streamInterceptor),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	logger.Printf("TLS: %v，客户端认证: %v", creds != nil, auth.enabled())
+
+	gs := grpc.NewServer(opts...)
+	pb.RegisterTokenServiceServer(gs, s)
+	healthpb.RegisterHealthServer(gs, hr.hs)
+	// 生产环境可通过 TOKEN_REFLECTION=false 关闭反射
+	if os.Getenv("TOKEN_REFLECTION") != "false" {
+		reflection.Register(gs)
+	}
 
 	logger.Println("TokenService gRPC 服务启动，监听 :51001")
-	if err := s.Serve(lis); err != nil {
+	if err := gs.Serve(lis); err != nil {
 		logger.Fatalf("gRPC 服务启动失败: %v", err)
 	}
 }
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	expireMarginSeconds = 100             // 上报的过期时间比微信返回的有效期提前的秒数
+	refreshAheadSeconds = 300             // 定时刷新比微信返回的有效期提前的秒数
+	minRefreshInterval  = 1 * time.Minute // 两次定时刷新的最小间隔
+)
+
+// 微信接口返回结构
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+}
+
+// refreshCall 一次进行中的上游刷新，并发的刷新请求等待同一个结果
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// tokenManager 负责单个公众号 access_token 的定时刷新与缓存
+type tokenManager struct {
+	app    appConfig
+	logger *log.Logger
+	store  *tokenStore // 为 nil 时为单机模式，不持久化
+
+	mu        sync.Mutex
+	token     string
+	expireAt  time.Time
+	refreshAt time.Time    // 下一次定时刷新时间
+	inflight  *refreshCall // 进行中的刷新（single-flight）
+
+	watchers map[chan struct{}]struct{} // WatchAccessToken 订阅者，token 变化时通知
+	tickets  map[string]*ticketCache    // JS-SDK / 卡券票据缓存，key 为票据类型
+}
+
+func newTokenManager(app appConfig, store *tokenStore, logger *log.Logger) *tokenManager {
+	return &tokenManager{app: app, store: store, logger: logger, tickets: newTicketCaches()}
+}
+
+// subscribe 订阅 token 变化通知，返回的 cancel 用于取消订阅
+func (m *tokenManager) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	if m.watchers == nil {
+		m.watchers = make(map[chan struct{}]struct{})
+	}
+	m.watchers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		delete(m.watchers, ch)
+		m.mu.Unlock()
+	}
+}
+
+// notifyLocked 通知所有订阅者 token 已变化（调用方需持有 m.mu），通知不阻塞
+func (m *tokenManager) notifyLocked() {
+	for ch := range m.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// get 返回当前缓存的 token 及过期时间
+func (m *tokenManager) get() (string, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token, m.expireAt
+}
+
+// fetch 请求微信接口获取新的 access_token，stable 模式下 force 表示强制刷新
+func (m *tokenManager) fetch(force bool) (*tokenResponse, error) {
+	if m.app.Mode == modeStable {
+		return m.fetchStable(force)
+	}
+
+	url := fmt.Sprintf("%s/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s", wechatBaseURL, m.app.AppID, m.app.AppSecret)
+
+	resp, err := wechatClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("请求微信接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeTokenResponse(resp.Body)
+}
+
+// stableTokenRequest cgi-bin/stable_token 请求体
+type stableTokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	AppID        string `json:"appid"`
+	Secret       string `json:"secret"`
+	ForceRefresh bool   `json:"force_refresh"`
+}
+
+// fetchStable 通过 cgi-bin/stable_token 获取稳定版 access_token，
+// 普通模式下有效期内重复获取不会使其他平台持有的 token 失效
+func (m *tokenManager) fetchStable(force bool) (*tokenResponse, error) {
+	url := wechatBaseURL + "/cgi-bin/stable_token"
+
+	data, err := json.Marshal(stableTokenRequest{
+		GrantType:    "client_credential",
+		AppID:        m.app.AppID,
+		Secret:       m.app.AppSecret,
+		ForceRefresh: force,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("请求体序列化失败: %v", err)
+	}
+
+	resp, err := wechatClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("请求微信接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeTokenResponse(resp.Body)
+}
+
+// decodeTokenResponse 解析微信 token 接口响应
+func decodeTokenResponse(r io.Reader) (*tokenResponse, error) {
+	body, _ := io.ReadAll(r)
+
+	var result tokenResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v, 原始响应: %s", err, string(body))
+	}
+
+	if result.ErrCode != 0 {
+		return nil, fmt.Errorf("微信返回错误: %s，原始响应: %s", result.ErrMsg, string(body))
+	}
+	return &result, nil
+}
+
+// obtain 获取一个新的 token：单机或主节点直接请求微信并写入 Redis，从节点请求主节点刷新后从 Redis 读取
+func (m *tokenManager) obtain(stale string, force bool) (*storedToken, error) {
+	if m.store != nil && !m.store.isLeader() {
+		return m.waitLeaderRefresh(stale)
+	}
+
+	start := time.Now()
+	result, err := m.fetch(force)
+	if err == nil && m.app.Mode == modeStable && !force && result.ExpiresIn <= refreshAheadSeconds {
+		// 非强制获取 stable_token 返回的是当前 token 及其剩余有效期，临近过期时需强制刷新，否则定时刷新会反复空转
+		m.logger.Printf("[%s] stable_token 剩余有效期 %ds，强制刷新", m.app.AppID, result.ExpiresIn)
+		result, err = m.fetch(true)
+	}
+	upstreamLatency.WithLabelValues(m.app.AppID).Observe(time.Since(start).Seconds())
+	if err != nil {
+		refreshFailCounter.WithLabelValues(m.app.AppID).Inc()
+		return nil, err
+	}
+	refreshCounter.WithLabelValues(m.app.AppID).Inc()
+	now := time.Now()
+	t := &storedToken{
+		AccessToken: result.AccessToken,
+		ExpireAt:    now.Add(time.Duration(result.ExpiresIn-expireMarginSeconds) * time.Second),
+		// 在上报的过期时间之前刷新，保证 Redis 及客户端中的 token 不会出现空窗
+		RefreshAt: now.Add(time.Duration(result.ExpiresIn-refreshAheadSeconds) * time.Second),
+	}
+	if t.RefreshAt.Before(now.Add(minRefreshInterval)) {
+		// 微信返回的有效期异常短时，限制刷新频率
+		t.RefreshAt = now.Add(minRefreshInterval)
+	}
+	m.logger.Printf("[%s] 成功刷新 access_token，有效期 %ds", m.app.AppID, result.ExpiresIn)
+
+	if m.store != nil {
+		if err := m.store.save(m.app.AppID, *t); err != nil {
+			m.logger.Printf("[%s] 保存 access_token 到 Redis 失败: %v", m.app.AppID, err)
+		}
+	}
+	return t, nil
+}
+
+// waitLeaderRefresh 从节点通知主节点刷新，并等待 Redis 中出现新的 token
+func (m *tokenManager) waitLeaderRefresh(stale string) (*storedToken, error) {
+	if stale == "" {
+		stale, _ = m.get()
+	}
+	if err := m.store.requestRefresh(m.app.AppID, stale); err != nil {
+		return nil, fmt.Errorf("请求主节点刷新失败: %v", err)
+	}
+
+	deadline := time.Now().Add(followerWaitPeriod)
+	for time.Now().Before(deadline) {
+		t, err := m.store.load(m.app.AppID)
+		if err == nil && t != nil && t.AccessToken != stale && time.Now().Before(t.ExpireAt) {
+			return t, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("等待主节点刷新 access_token 超时")
+}
+
+// handleRefreshRequest 主节点处理从节点的刷新请求。stale 为空表示从节点尚未持有 token（例如刚重启），
+// 此时若当前 token 仍有效则不请求微信，从节点会从 Redis 读取
+func (m *tokenManager) handleRefreshRequest(stale string) error {
+	if stale == "" {
+		if token, expireAt := m.get(); token != "" && time.Now().Before(expireAt) {
+			return nil
+		}
+	}
+	_, _, err := m.refresh(stale, true)
+	return err
+}
+
+// syncFromStore 从 Redis 加载其他实例刷新的 token（更新的才采用）
+func (m *tokenManager) syncFromStore() {
+	t, err := m.store.load(m.app.AppID)
+	if err != nil {
+		m.logger.Printf("[%s] 从 Redis 读取 access_token 失败: %v", m.app.AppID, err)
+		return
+	}
+	if t == nil || !time.Now().Before(t.ExpireAt) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Redis 中的时间精确到秒，同一秒内刷新的 token 也应采用
+	if t.AccessToken != m.token && t.ExpireAt.Unix() >= m.expireAt.Unix() {
+		m.token = t.AccessToken
+		m.expireAt = t.ExpireAt
+		m.refreshAt = t.RefreshAt
+		m.notifyLocked()
+		m.logger.Printf("[%s] 从 Redis 同步 access_token，过期时间 %s", m.app.AppID, t.ExpireAt.Format("2006-01-02 15:04:05"))
+	}
+}
+
+// refresh 获取新 token，并发调用只会触发一次上游请求，其余调用共享结果。
+// stale 不为空且与当前 token 不一致时，说明 token 已被刷新过，直接返回当前 token。
+// force 为 true 时（调用方报告 token 失效），stable 模式使用 force_refresh 强制刷新。
+func (m *tokenManager) refresh(stale string, force bool) (string, time.Time, error) {
+	m.mu.Lock()
+	if stale != "" && m.token != "" && m.token != stale {
+		token, expireAt := m.token, m.expireAt
+		m.mu.Unlock()
+		return token, expireAt, nil
+	}
+	if c := m.inflight; c != nil {
+		m.mu.Unlock()
+		<-c.done
+		token, expireAt := m.get()
+		return token, expireAt, c.err
+	}
+	c := &refreshCall{done: make(chan struct{})}
+	m.inflight = c
+	m.mu.Unlock()
+
+	t, err := m.obtain(stale, force)
+
+	m.mu.Lock()
+	if err == nil {
+		m.token = t.AccessToken
+		m.expireAt = t.ExpireAt
+		m.refreshAt = t.RefreshAt
+		m.notifyLocked()
+	}
+	m.inflight = nil
+	token, expireAt := m.token, m.expireAt
+	m.mu.Unlock()
+
+	c.err = err
+	close(c.done)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expireAt, nil
+}
+
+// loop 定时获取 token；启用 Redis 时优先复用已保存的 token，仅主节点到期后请求微信
+func (m *tokenManager) loop() {
+	for {
+		if m.store != nil {
+			m.syncFromStore()
+			if !m.store.isLeader() {
+				time.Sleep(storeSyncInterval)
+				continue
+			}
+		}
+
+		// 休眠到刷新时间，期间若被强制刷新则顺延
+		m.mu.Lock()
+		wait := time.Until(m.refreshAt)
+		m.mu.Unlock()
+		if wait > 0 {
+			if m.store != nil && wait > storeSyncInterval {
+				wait = storeSyncInterval
+			}
+			time.Sleep(wait)
+			continue
+		}
+
+		if _, _, err := m.refresh("", false); err != nil {
+			m.logger.Printf("[%s] %v", m.app.AppID, err)
+			time.Sleep(10 * time.Second)
+		}
+	}
+}
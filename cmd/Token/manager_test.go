@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "vxmsgpush/core/grpc/token"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeWechat 模拟微信 token 与 getticket 接口，每次请求返回新的 token / 票据
+type fakeWechat struct {
+	calls           atomic.Int32
+	ticketCalls     atomic.Int32
+	delay           time.Duration
+	ticketExpiresIn int64 // 票据有效期，默认 7200
+}
+
+func (f *fakeWechat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/cgi-bin/ticket/getticket") {
+		n := f.ticketCalls.Add(1)
+		expiresIn := f.ticketExpiresIn
+		if expiresIn == 0 {
+			expiresIn = 7200
+		}
+		json.NewEncoder(w).Encode(ticketResponse{Ticket: fmt.Sprintf("ticket-%d", n), ExpiresIn: expiresIn})
+		return
+	}
+	n := f.calls.Add(1)
+	time.Sleep(f.delay)
+	json.NewEncoder(w).Encode(tokenResponse{AccessToken: fmt.Sprintf("token-%d", n), ExpiresIn: 7200})
+}
+
+// useFakeWechat 将微信接口指向 handler，测试结束后恢复
+func useFakeWechat(t *testing.T, handler http.Handler) {
+	srv := httptest.NewServer(handler)
+	oldURL, oldClient := wechatBaseURL, wechatClient
+	wechatBaseURL, wechatClient = srv.URL, srv.Client()
+	t.Cleanup(func() {
+		srv.Close()
+		wechatBaseURL, wechatClient = oldURL, oldClient
+	})
+}
+
+func newTestManager(store *tokenStore) *tokenManager {
+	app := appConfig{AppID: "wx-test", AppSecret: "secret", Mode: modeToken}
+	return newTokenManager(app, store, log.New(io.Discard, "", 0))
+}
+
+func TestRefreshSingleFlight(t *testing.T) {
+	upstream := &fakeWechat{delay: 100 * time.Millisecond}
+	useFakeWechat(t, upstream)
+	m := newTestManager(nil)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, _, err := m.refresh("", true)
+			if err != nil {
+				t.Errorf("刷新失败: %v", err)
+			}
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+
+	if n := upstream.calls.Load(); n != 1 {
+		t.Errorf("并发刷新应只请求微信 1 次，实际 %d 次", n)
+	}
+	for i, token := range tokens {
+		if token != "token-1" {
+			t.Errorf("第 %d 个调用拿到 %q，期望 token-1", i, token)
+		}
+	}
+
+	// 调用方持有的 token 已被刷新过时直接返回当前 token
+	if token, _, _ := m.refresh("token-0", true); token != "token-1" || upstream.calls.Load() != 1 {
+		t.Errorf("stale 与当前 token 不一致时不应请求微信: %s，请求 %d 次", token, upstream.calls.Load())
+	}
+}
+
+func TestRefreshAccessTokenRPC(t *testing.T) {
+	upstream := &fakeWechat{}
+	useFakeWechat(t, upstream)
+	m := newTestManager(nil)
+	s := &server{managers: map[string]*tokenManager{"wx-test": m}, defaultAppID: "wx-test"}
+
+	reply, err := s.RefreshAccessToken(context.Background(), &pb.RefreshRequest{})
+	if err != nil || reply.AccessToken != "token-1" || reply.Appid != "wx-test" {
+		t.Fatalf("强制刷新失败: %v %v", reply, err)
+	}
+
+	// 其他调用方已刷新过：携带旧 token 不再请求微信
+	reply, err = s.RefreshAccessToken(context.Background(), &pb.RefreshRequest{StaleToken: "token-0"})
+	if err != nil || reply.AccessToken != "token-1" || upstream.calls.Load() != 1 {
+		t.Errorf("携带旧 token 时应直接返回当前 token: %v %v，请求 %d 次", reply, err, upstream.calls.Load())
+	}
+
+	// 携带当前 token 说明其已失效，需要刷新
+	reply, err = s.RefreshAccessToken(context.Background(), &pb.RefreshRequest{StaleToken: "token-1"})
+	if err != nil || reply.AccessToken != "token-2" {
+		t.Errorf("当前 token 失效时应刷新: %v %v", reply, err)
+	}
+
+	if _, err := s.RefreshAccessToken(context.Background(), &pb.RefreshRequest{Appid: "wx-unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("未配置的 appid 应返回 NotFound，实际 %v", err)
+	}
+}
+
+func TestLeaderFollowerHandoff(t *testing.T) {
+	upstream := &fakeWechat{}
+	useFakeWechat(t, upstream)
+	rdb := newFakeRedis(t)
+
+	storeA, storeB := rdb.newStore(t, "a"), rdb.newStore(t, "b")
+	storeA.elect()
+	storeB.elect()
+	if !storeA.isLeader() || storeB.isLeader() {
+		t.Fatalf("期望 a 为主节点、b 为从节点: a=%v b=%v", storeA.isLeader(), storeB.isLeader())
+	}
+
+	leader, follower := newTestManager(storeA), newTestManager(storeB)
+	for _, s := range []struct {
+		store *tokenStore
+		m     *tokenManager
+	}{{storeA, leader}, {storeB, follower}} {
+		s := s
+		go s.store.watchRefreshRequests(func(appID, stale string) {
+			if err := s.m.handleRefreshRequest(stale); err != nil {
+				t.Errorf("处理刷新请求失败: %v", err)
+			}
+		}, func(appID, typ string) {
+			if _, _, err := s.m.ticket(typ); err != nil {
+				t.Errorf("处理票据请求失败: %v", err)
+			}
+		})
+	}
+	time.Sleep(100 * time.Millisecond) // 等待订阅建立
+
+	token, _, err := leader.refresh("", false)
+	if err != nil || token != "token-1" {
+		t.Fatalf("主节点刷新失败: %s %v", token, err)
+	}
+
+	// 刚启动的从节点没有 token：主节点 token 仍有效，不应请求微信，从节点从 Redis 读取
+	token, _, err = follower.refresh("", true)
+	if err != nil || token != "token-1" {
+		t.Fatalf("从节点应拿到主节点的 token: %s %v", token, err)
+	}
+	if n := upstream.calls.Load(); n != 1 {
+		t.Errorf("从节点重启不应触发微信刷新，实际请求 %d 次", n)
+	}
+
+	// 从节点报告 token 失效：由主节点刷新
+	token, _, err = follower.refresh("token-1", true)
+	if err != nil || token != "token-2" {
+		t.Fatalf("从节点报告失效后应拿到新 token: %s %v", token, err)
+	}
+	if got, _ := leader.get(); got != "token-2" {
+		t.Errorf("应由主节点刷新，主节点当前 token: %s", got)
+	}
+
+	// 从节点的票据由主节点获取，并发请求只调用一次 getticket
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ticket, _, err := follower.ticket(ticketJsapi); err != nil || ticket != "ticket-1" {
+				t.Errorf("从节点应拿到主节点获取的票据: %s %v", ticket, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := upstream.ticketCalls.Load(); n != 1 {
+		t.Errorf("期望只由主节点请求 getticket 1 次，实际 %d 次", n)
+	}
+
+	// 主节点锁过期后 b 接管，a 续期失败后不再是主节点
+	rdb.del(leaderLockKey)
+	storeB.elect()
+	storeA.elect()
+	if storeA.isLeader() || !storeB.isLeader() {
+		t.Fatalf("期望 b 接管主节点: a=%v b=%v", storeA.isLeader(), storeB.isLeader())
+	}
+
+	token, _, err = follower.refresh("token-2", true)
+	if err != nil || token != "token-3" {
+		t.Fatalf("新主节点刷新失败: %s %v", token, err)
+	}
+	leader.syncFromStore()
+	if got, _ := leader.get(); got != "token-3" {
+		t.Errorf("原主节点应从 Redis 同步新 token，实际 %s", got)
+	}
+	if n := upstream.calls.Load(); n != 3 {
+		t.Errorf("期望共请求微信 3 次，实际 %d 次", n)
+	}
+}
+
+func TestStableTokenForcedNearExpiry(t *testing.T) {
+	var forced atomic.Int32
+	useFakeWechat(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req stableTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.ForceRefresh {
+			// 非强制获取返回当前 token 的剩余有效期
+			json.NewEncoder(w).Encode(tokenResponse{AccessToken: "old", ExpiresIn: 200})
+			return
+		}
+		forced.Add(1)
+		json.NewEncoder(w).Encode(tokenResponse{AccessToken: "new", ExpiresIn: 7200})
+	}))
+	m := newTestManager(nil)
+	m.app.Mode = modeStable
+
+	token, expireAt, err := m.refresh("", false)
+	if err != nil || token != "new" || forced.Load() != 1 {
+		t.Fatalf("剩余有效期不足时应强制刷新: %s %v，强制次数 %d", token, err, forced.Load())
+	}
+	if !expireAt.After(time.Now()) {
+		t.Errorf("过期时间不应早于当前时间: %v", expireAt)
+	}
+	m.mu.Lock()
+	refreshAt := m.refreshAt
+	m.mu.Unlock()
+	if time.Until(refreshAt) < minRefreshInterval {
+		t.Errorf("下次刷新时间过早: %v", refreshAt)
+	}
+}
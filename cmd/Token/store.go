@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tokenKeyPrefix     = "wx_access_token:"          // 每个公众号一个 hash：access_token / expire_at / refresh_at
+	ticketKeyPrefix    = "wx_ticket:"                // 每个公众号每种票据一个 hash：ticket / expire_at
+	leaderLockKey      = "wx_token_service_leader"   // 主节点锁，只有持有者会请求微信刷新
+	refreshChannel     = "wx_token_refresh_request"  // 从节点请求主节点强制刷新
+	ticketChannel      = "wx_ticket_refresh_request" // 从节点请求主节点获取票据
+	leaderTTL          = 15 * time.Second
+	leaderRenewPeriod  = 5 * time.Second
+	storeSyncInterval  = 5 * time.Second // 从节点同步 Redis 中 token 的间隔
+	followerWaitPeriod = 8 * time.Second // 从节点等待主节点刷新的最长时间
+)
+
+// 仅当锁仍由自己持有时续期
+var renewLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// storedToken Redis 中保存的 token 信息
+type storedToken struct {
+	AccessToken string
+	ExpireAt    time.Time
+	RefreshAt   time.Time
+}
+
+// storedTicket Redis 中保存的 JS-SDK / 卡券票据
+type storedTicket struct {
+	Ticket   string
+	ExpireAt time.Time
+}
+
+// tokenStore 基于 Redis 持久化 token，并通过分布式锁选举唯一刷新节点
+type tokenStore struct {
+	rdb    *redis.Client
+	id     string
+	logger *log.Logger
+	leader atomic.Bool
+}
+
+// newTokenStore 根据 TOKEN_REDIS_* 环境变量连接 Redis，未配置 TOKEN_REDIS_ADDR 时返回 nil（单机模式）
+func newTokenStore(logger *log.Logger) (*tokenStore, error) {
+	addr := os.Getenv("TOKEN_REDIS_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	db := 0
+	if v := os.Getenv("TOKEN_REDIS_DB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("TOKEN_REDIS_DB 配置错误: %v", err)
+		}
+		db = n
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: os.Getenv("TOKEN_REDIS_PASSWORD"),
+		DB:       db,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("连接 Redis 失败: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	return &tokenStore{
+		rdb:    rdb,
+		id:     fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		logger: logger,
+	}, nil
+}
+
+// isLeader 当前实例是否为刷新主节点
+func (s *tokenStore) isLeader() bool {
+	return s.leader.Load()
+}
+
+// runElection 周期性抢占/续期主节点锁
+func (s *tokenStore) runElection() {
+	for {
+		time.Sleep(leaderRenewPeriod)
+		s.elect()
+	}
+}
+
+// elect 抢占或续期一次主节点锁
+func (s *tokenStore) elect() {
+	ctx := context.Background()
+	var ok bool
+	var err error
+	if s.isLeader() {
+		var n int64
+		n, err = renewLockScript.Run(ctx, s.rdb, []string{leaderLockKey}, s.id, leaderTTL.Milliseconds()).Int64()
+		ok = n == 1
+	} else {
+		ok, err = s.rdb.SetNX(ctx, leaderLockKey, s.id, leaderTTL).Result()
+	}
+	if err != nil {
+		s.logger.Printf("[store] 主节点选举失败: %v", err)
+		ok = false
+	}
+
+	if ok != s.isLeader() {
+		if ok {
+			s.logger.Printf("[store] 当前实例 %s 成为主节点，负责刷新 access_token", s.id)
+		} else {
+			s.logger.Printf("[store] 当前实例 %s 失去主节点身份", s.id)
+		}
+	}
+	s.leader.Store(ok)
+}
+
+// load 读取 Redis 中保存的 token，不存在时返回 nil
+func (s *tokenStore) load(appID string) (*storedToken, error) {
+	vals, err := s.rdb.HGetAll(context.Background(), tokenKeyPrefix+appID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals["access_token"] == "" {
+		return nil, nil
+	}
+	expireAt, _ := strconv.ParseInt(vals["expire_at"], 10, 64)
+	refreshAt, _ := strconv.ParseInt(vals["refresh_at"], 10, 64)
+	return &storedToken{
+		AccessToken: vals["access_token"],
+		ExpireAt:    time.Unix(expireAt, 0),
+		RefreshAt:   time.Unix(refreshAt, 0),
+	}, nil
+}
+
+// save 保存 token，key 在 token 过期后自动删除
+func (s *tokenStore) save(appID string, t storedToken) error {
+	ctx := context.Background()
+	key := tokenKeyPrefix + appID
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key,
+		"access_token", t.AccessToken,
+		"expire_at", t.ExpireAt.Unix(),
+		"refresh_at", t.RefreshAt.Unix(),
+	)
+	pipe.ExpireAt(ctx, key, t.ExpireAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// loadTicket 读取 Redis 中保存的票据，不存在时返回 nil
+func (s *tokenStore) loadTicket(appID, typ string) (*storedTicket, error) {
+	vals, err := s.rdb.HGetAll(context.Background(), ticketKeyPrefix+appID+":"+typ).Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals["ticket"] == "" {
+		return nil, nil
+	}
+	expireAt, _ := strconv.ParseInt(vals["expire_at"], 10, 64)
+	return &storedTicket{Ticket: vals["ticket"], ExpireAt: time.Unix(expireAt, 0)}, nil
+}
+
+// saveTicket 保存票据，key 在票据过期后自动删除
+func (s *tokenStore) saveTicket(appID, typ string, t storedTicket) error {
+	ctx := context.Background()
+	key := ticketKeyPrefix + appID + ":" + typ
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key, "ticket", t.Ticket, "expire_at", t.ExpireAt.Unix())
+	pipe.ExpireAt(ctx, key, t.ExpireAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// requestRefresh 从节点请求主节点强制刷新
+func (s *tokenStore) requestRefresh(appID, stale string) error {
+	return s.rdb.Publish(context.Background(), refreshChannel, appID+"\t"+stale).Err()
+}
+
+// requestTicketRefresh 从节点请求主节点获取票据
+func (s *tokenStore) requestTicketRefresh(appID, typ string) error {
+	return s.rdb.Publish(context.Background(), ticketChannel, appID+"\t"+typ).Err()
+}
+
+// watchRefreshRequests 订阅从节点的 token 强制刷新与票据获取请求，仅主节点处理
+func (s *tokenStore) watchRefreshRequests(handleToken func(appID, stale string), handleTicket func(appID, typ string)) {
+	sub := s.rdb.Subscribe(context.Background(), refreshChannel, ticketChannel)
+	for msg := range sub.Channel() {
+		if !s.isLeader() {
+			continue
+		}
+		appID, arg, _ := strings.Cut(msg.Payload, "\t")
+		if msg.Channel == ticketChannel {
+			handleTicket(appID, arg)
+		} else {
+			handleToken(appID, arg)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	pb "vxmsgpush/core/grpc/token"
+	"vxmsgpush/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 票据类型
+const (
+	ticketJsapi  = "jsapi"   // JS-SDK jsapi_ticket
+	ticketWxCard = "wx_card" // 卡券 api_ticket
+)
+
+// 微信 getticket 接口返回结构
+type ticketResponse struct {
+	ErrCode   int    `json:"errcode"`
+	ErrMsg    string `json:"errmsg"`
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// ticketCache 单个类型票据的缓存，mu 串行化获取以避免并发重复请求微信
+type ticketCache struct {
+	mu       sync.Mutex
+	ticket   string
+	expireAt time.Time
+}
+
+func newTicketCaches() map[string]*ticketCache {
+	return map[string]*ticketCache{
+		ticketJsapi:  {},
+		ticketWxCard: {},
+	}
+}
+
+// ticket 返回缓存的票据，过期时依次尝试 Redis 与微信接口
+func (m *tokenManager) ticket(typ string) (string, time.Time, error) {
+	tc, ok := m.tickets[typ]
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("不支持的票据类型: %s", typ)
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if tc.ticket != "" && time.Now().Before(tc.expireAt) {
+		return tc.ticket, tc.expireAt, nil
+	}
+
+	if m.store != nil {
+		t, err := m.store.loadTicket(m.app.AppID, typ)
+		if err != nil {
+			m.logger.Printf("[%s] 从 Redis 读取 %s 票据失败: %v", m.app.AppID, typ, err)
+		} else if t != nil && time.Now().Before(t.ExpireAt) {
+			tc.ticket, tc.expireAt = t.Ticket, t.ExpireAt
+			return tc.ticket, tc.expireAt, nil
+		}
+	}
+
+	if m.store != nil && !m.store.isLeader() {
+		// 从节点不直接请求微信，由主节点获取后写入 Redis
+		t, err := m.waitLeaderTicket(typ)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		tc.ticket, tc.expireAt = t.Ticket, t.ExpireAt
+		return tc.ticket, tc.expireAt, nil
+	}
+
+	token, _ := m.get()
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("暂无有效的 access_token")
+	}
+	result, err := m.fetchTicket(token, typ)
+	if err == nil && utils.IsTokenInvalid(result.ErrCode) {
+		// access_token 已失效，强制刷新后重试一次
+		if token, _, err = m.refresh(token, true); err == nil {
+			result, err = m.fetchTicket(token, typ)
+		}
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if result.ErrCode != 0 {
+		return "", time.Time{}, fmt.Errorf("微信返回错误: %d - %s", result.ErrCode, result.ErrMsg)
+	}
+
+	now := time.Now()
+	tc.ticket = result.Ticket
+	tc.expireAt = now.Add(time.Duration(result.ExpiresIn-expireMarginSeconds) * time.Second)
+	if tc.expireAt.Before(now.Add(minRefreshInterval)) {
+		// 微信返回的有效期异常短时，与 access_token 一样限制请求频率，避免每次调用都请求 getticket
+		tc.expireAt = now.Add(minRefreshInterval)
+	}
+	m.logger.Printf("[%s] 成功获取 %s 票据，有效期 %ds", m.app.AppID, typ, result.ExpiresIn)
+
+	if m.store != nil {
+		if err := m.store.saveTicket(m.app.AppID, typ, storedTicket{Ticket: tc.ticket, ExpireAt: tc.expireAt}); err != nil {
+			m.logger.Printf("[%s] 保存 %s 票据到 Redis 失败: %v", m.app.AppID, typ, err)
+		}
+	}
+	return tc.ticket, tc.expireAt, nil
+}
+
+// waitLeaderTicket 从节点请求主节点获取票据，并等待 Redis 中出现有效的票据
+func (m *tokenManager) waitLeaderTicket(typ string) (*storedTicket, error) {
+	if err := m.store.requestTicketRefresh(m.app.AppID, typ); err != nil {
+		return nil, fmt.Errorf("请求主节点获取 %s 票据失败: %v", typ, err)
+	}
+
+	deadline := time.Now().Add(followerWaitPeriod)
+	for time.Now().Before(deadline) {
+		t, err := m.store.loadTicket(m.app.AppID, typ)
+		if err == nil && t != nil && time.Now().Before(t.ExpireAt) {
+			return t, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("等待主节点获取 %s 票据超时", typ)
+}
+
+// fetchTicket 请求微信 getticket 接口，微信错误码通过 ErrCode 返回
+func (m *tokenManager) fetchTicket(accessToken, typ string) (*ticketResponse, error) {
+	url := fmt.Sprintf("%s/cgi-bin/ticket/getticket?access_token=%s&type=%s", wechatBaseURL, accessToken, typ)
+
+	resp, err := wechatClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("请求微信接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var result ticketResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v, 原始响应: %s", err, string(body))
+	}
+	return &result, nil
+}
+
+// jsapiSignature 按 JS-SDK 规则计算签名：参数按字段名字典序拼接后取 SHA1
+func jsapiSignature(ticket, nonceStr string, timestamp int64, url string) string {
+	// 签名使用的 URL 不包含 # 及其后面部分
+	if i := strings.Index(url, "#"); i >= 0 {
+		url = url[:i]
+	}
+	str := fmt.Sprintf("jsapi_ticket=%s&noncestr=%s&timestamp=%d&url=%s", ticket, nonceStr, timestamp, url)
+	sum := sha1.Sum([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomNonce 生成随机字符串
+func randomNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// gRPC 方法：返回缓存的 jsapi_ticket / 卡券 api_ticket
+func (s *server) GetJsapiTicket(ctx context.Context, req *pb.TicketRequest) (*pb.TicketReply, error) {
+	m, err := s.manager(req.GetAppid())
+	if err != nil {
+		return nil, err
+	}
+	typ := req.GetType()
+	if typ == "" {
+		typ = ticketJsapi
+	}
+	if _, ok := m.tickets[typ]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的票据类型: %s", typ)
+	}
+
+	ticket, expireAt, err := m.ticket(typ)
+	if err != nil {
+		m.logger.Printf("[%s] 获取 %s 票据失败: %v", m.app.AppID, typ, err)
+		return nil, status.Errorf(codes.Unavailable, "获取票据失败: %v", err)
+	}
+	return &pb.TicketReply{
+		Ticket:   ticket,
+		ExpireAt: expireAt.Unix(),
+		Appid:    m.app.AppID,
+		Type:     typ,
+	}, nil
+}
+
+// gRPC 方法：生成 JS-SDK 签名
+func (s *server) SignJsapi(ctx context.Context, req *pb.SignRequest) (*pb.SignReply, error) {
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "url 不能为空")
+	}
+	m, err := s.manager(req.GetAppid())
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, _, err := m.ticket(ticketJsapi)
+	if err != nil {
+		m.logger.Printf("[%s] 获取 jsapi_ticket 失败: %v", m.app.AppID, err)
+		return nil, status.Errorf(codes.Unavailable, "获取 jsapi_ticket 失败: %v", err)
+	}
+
+	nonceStr := req.GetNonceStr()
+	if nonceStr == "" {
+		if nonceStr, err = randomNonce(); err != nil {
+			return nil, status.Errorf(codes.Internal, "生成随机字符串失败: %v", err)
+		}
+	}
+	timestamp := req.GetTimestamp()
+	if timestamp == 0 {
+		timestamp = time.Now().Unix()
+	}
+
+	return &pb.SignReply{
+		Appid:     m.app.AppID,
+		NonceStr:  nonceStr,
+		Timestamp: timestamp,
+		Signature: jsapiSignature(ticket, nonceStr, timestamp, req.GetUrl()),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicketShortExpiry(t *testing.T) {
+	upstream := &fakeWechat{ticketExpiresIn: 60}
+	useFakeWechat(t, upstream)
+	m := newTestManager(nil)
+	if _, _, err := m.refresh("", false); err != nil {
+		t.Fatalf("获取 access_token 失败: %v", err)
+	}
+
+	ticket, expireAt, err := m.ticket(ticketJsapi)
+	if err != nil || ticket != "ticket-1" {
+		t.Fatalf("获取票据失败: %s %v", ticket, err)
+	}
+	if time.Until(expireAt) < minRefreshInterval-time.Second {
+		t.Errorf("有效期异常短时过期时间应不早于 %v 后，实际 %v", minRefreshInterval, expireAt)
+	}
+
+	// 有效期内再次获取使用缓存
+	for i := 0; i < 3; i++ {
+		if ticket, _, _ := m.ticket(ticketJsapi); ticket != "ticket-1" {
+			t.Errorf("期望使用缓存的票据，实际 %s", ticket)
+		}
+	}
+	if n := upstream.ticketCalls.Load(); n != 1 {
+		t.Errorf("期望只请求 getticket 1 次，实际 %d 次", n)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "vxmsgpush/core/grpc/token"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// startTestServer 在本地端口启动 TokenService，返回连接到它的客户端
+func startTestServer(t *testing.T, s *server) pb.TokenServiceClient {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	gs := grpc.NewServer()
+	pb.RegisterTokenServiceServer(gs, s)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("连接 TokenService 失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewTokenServiceClient(conn)
+}
+
+func TestWatchAccessToken(t *testing.T) {
+	upstream := &fakeWechat{}
+	useFakeWechat(t, upstream)
+	m := newTestManager(nil)
+	if _, _, err := m.refresh("", false); err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	client := startTestServer(t, &server{managers: map[string]*tokenManager{"wx-test": m}, defaultAppID: "wx-test"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchAccessToken(ctx, &pb.TokenRequest{})
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 订阅后立即推送当前 token
+	reply, err := stream.Recv()
+	if err != nil || reply.AccessToken != "token-1" || reply.Appid != "wx-test" {
+		t.Fatalf("首次推送应为当前 token: %v %v", reply, err)
+	}
+
+	// token 刷新后推送新 token
+	if _, _, err := m.refresh("token-1", true); err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	reply, err = stream.Recv()
+	if err != nil || reply.AccessToken != "token-2" {
+		t.Fatalf("刷新后应推送新 token: %v %v", reply, err)
+	}
+	if !time.Unix(reply.ExpireAt, 0).After(time.Now()) {
+		t.Errorf("推送的过期时间错误: %d", reply.ExpireAt)
+	}
+
+	// 客户端断开后取消订阅
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		n := len(m.watchers)
+		m.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("客户端断开后仍有 %d 个订阅者", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
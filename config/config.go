@@ -31,6 +31,52 @@ type SecurityConfig struct {
 	AllowedIPs []string `toml:"allowed_ips"`
 }
 
+// WechatConfig 微信接口网关配置
+type WechatConfig struct {
+	BaseURL string `toml:"base_url"` // 微信接口前缀，默认 http://192.170.144.52:9010/weixin_api
+	Timeout int    `toml:"timeout"`  // 请求超时（秒），默认 5
+	Proxy   string `toml:"proxy"`    // HTTP 代理地址，为空时不使用代理
+}
+
+// TemplateConfig 模板消息配置
+type TemplateConfig struct {
+	Normalize bool `toml:"normalize"` // 发送前按字段类型截断超长内容、去除不允许的字符并转换日期格式
+}
+
+// CallbackConfig 微信回调（服务器配置）参数，与公众号后台“服务器配置”保持一致
+type CallbackConfig struct {
+	Token           string `toml:"token"`            // 令牌，用于签名校验
+	EncodingAESKey  string `toml:"encoding_aes_key"` // 消息加解密密钥（43 位），明文模式可不配置
+	Mode            string `toml:"mode"`             // 消息加解密方式：plain（默认）/ compatible / safe
+	AppID           string `toml:"appid"`            // 回调所属公众号 appid，为空表示默认公众号（解密时使用 vxkey.appid 校验）
+	TimestampWindow int    `toml:"timestamp_window"` // 允许的请求时间戳偏差（秒），默认 300
+	DedupTTL        int    `toml:"dedup_ttl"`        // 消息去重记录保留时间（秒），默认 300
+}
+
+// WebhookConfig 回调转发订阅，按消息类型 / 事件类型与 appid 过滤，均为空时转发全部
+type WebhookConfig struct {
+	Name     string   `toml:"name"`      // 订阅名称，唯一
+	URL      string   `toml:"url"`       // 接收地址
+	Secret   string   `toml:"secret"`    // HMAC-SHA256 签名密钥
+	AppIDs   []string `toml:"appids"`    // 订阅的公众号，为空表示全部
+	MsgTypes []string `toml:"msg_types"` // 订阅的普通消息类型，如 text、image
+	Events   []string `toml:"events"`    // 订阅的事件类型，如 CLICK、subscribe
+	Timeout  int      `toml:"timeout"`   // 请求超时（秒），默认 3
+	MaxRetry int      `toml:"max_retry"` // 最大重试次数，默认 5
+}
+
+// TokenServiceConfig TokenService gRPC 客户端配置
+type TokenServiceConfig struct {
+	Addr       string `toml:"addr"`        // 默认 127.0.0.1:51001
+	TLS        bool   `toml:"tls"`         // 是否使用 TLS 连接
+	CAFile     string `toml:"ca_file"`     // 校验服务端证书的 CA，为空时使用系统 CA
+	CertFile   string `toml:"cert_file"`   // mTLS 客户端证书
+	KeyFile    string `toml:"key_file"`    // mTLS 客户端私钥
+	ServerName string `toml:"server_name"` // 覆盖证书校验使用的服务端名称
+	ClientID   string `toml:"client_id"`
+	ClientKey  string `toml:"client_key"`
+}
+
 type RedisConfig struct {
 	Addr     string `toml:"addr"`
 	Password string `toml:"password"`
@@ -51,6 +97,11 @@ type Config struct {
 	Security SecurityConfig `toml:"security"`
 	Redis    RedisConfig    `toml:"redis"`
 	MySQL   MySQLConfig   `toml:"mysql"`
+	Token   TokenServiceConfig `toml:"token"`
+	Wechat  WechatConfig       `toml:"wechat"`
+	Template TemplateConfig    `toml:"template"`
+	Callback CallbackConfig    `toml:"callback"`
+	Webhooks []WebhookConfig   `toml:"webhook"`
 }
 
 var Conf Config
@@ -73,3 +124,11 @@ func InitConfig() {
 	}
 }
 
+// NormalizeAppID 将默认公众号（vxkey.appid）统一表示为空字符串，
+// 队列消息、回调记录与关注用户表都以该形式标识公众号
+func NormalizeAppID(appid string) string {
+	if appid == Conf.VxKey.AppId {
+		return ""
+	}
+	return appid
+}
@@ -2,6 +2,8 @@ package consumer
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -15,26 +17,44 @@ import (
 )
 
 type statTask struct {
-	Type   string // "push_stat" | "user_stat" | "openid"
+	Type   string // "push_stat" | "user_stat" | "openid" | "message"
 	AppID  string
 	Mobile string
 	OpenID string
 	OK     bool
 	Time   time.Time
+	Record db.MessageRecord // Type 为 "message" 时使用
 }
 
 var statChan = make(chan statTask, 1000)
 
-type RedisTemplateMessage struct {
+// 队列消息类型
+const (
+	MsgTypeTemplate  = "template"  // 模板消息（默认）
+	MsgTypeSubscribe = "subscribe" // 订阅通知
+	MsgTypeCustom    = "custom"    // 客服消息
+)
+
+// RedisMessage 队列中的消息，Type 决定使用哪些字段；不同类型共用 worker、重试、死信队列与统计
+type RedisMessage struct {
+	Type        string                 `json:"type,omitempty"`       // 消息类型，为空时按模板消息处理（兼容旧消息）
+	RequestID   string                 `json:"request_id,omitempty"` // 请求 ID，用于查询单条消息投递记录
 	Mobile      string                 `json:"mobile"`
-	TemplateID  string                 `json:"template_id"`
-	URL         string                 `json:"url"`
-	Data        map[string]interface{} `json:"data"`
+	TemplateID  string                 `json:"template_id,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	Page        string                 `json:"page,omitempty"` // 订阅通知的跳转网页
+	Data        map[string]interface{} `json:"data,omitempty"`
 	MiniProgram *vxmsg.MiniProgram     `json:"miniprogram,omitempty"`
+	Custom      *vxmsg.CustomMsg       `json:"custom,omitempty"`      // 客服消息内容，touser 为空时按 mobile 查询
 	RetryCount  int                    `json:"retry_count,omitempty"` // 重试次数
 	AppID       string                 `json:"appid,omitempty"`       // 用来存 Header 的 AppID
 }
 
+// invalidMessageError 消息内容无效，重试也不会成功
+type invalidMessageError struct {
+	error
+}
+
 var ctx = context.Background()
 
 const (
@@ -45,6 +65,13 @@ const (
 	sendRatePerSecond = 200                     // 限制发送频率， 条/秒
 )
 
+// NewRequestID 生成消息请求 ID：时间前缀便于排查，随机后缀保证唯一
+func NewRequestID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return time.Now().Format("20060102150405") + hex.EncodeToString(b)
+}
+
 func StartStatWriter() {
 	go func() {
 		for task := range statChan {
@@ -54,13 +81,24 @@ func StartStatWriter() {
 					logger.Warnf("[stat-writer] push_stat 更新失败: %v", err)
 				}
 			case "user_stat":
+				if task.Mobile == "" {
+					// 只有 openid 的客服消息不计入用户统计
+					continue
+				}
 				if err := db.UpdateUserSendStatWithAppID(task.Mobile, task.OpenID, task.AppID, task.OK); err != nil {
 					logger.Warnf("[stat-writer] user_stat 更新失败: %v", err)
 				}
 			case "openid":
+				if task.Mobile == "" {
+					continue
+				}
 				if err := db.UpdateUserOpenIDWithAppID(task.Mobile, task.OpenID, task.AppID); err != nil {
 					logger.Warnf("[stat-writer] 更新openid失败: %v", err)
 				}
+			case "message":
+				if err := db.SaveMessageRecord(task.Record); err != nil {
+					logger.Warnf("[stat-writer] 消息记录更新失败: %v", err)
+				}
 			}
 		}
 	}()
@@ -157,19 +195,42 @@ func StartRetryScheduler(rdb *redis.Client, delayQueue, mainQueue string, batchS
 }
 
 func processMessage(rdb *redis.Client, raw string, id int) {
-	var msg RedisTemplateMessage
+	var msg RedisMessage
 	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
 		logger.Errorf("[worker-%d] JSON 解析失败: %v，内容: %s", id, err, raw)
 		AddFailWithReason("invalid_json", "") // 无法解析时没有 AppID
 		return
 	}
+	if msg.RequestID == "" {
+		// 兼容升级前入队、没有请求 ID 的消息
+		msg.RequestID = NewRequestID()
+	}
+	if msg.Type == "" {
+		msg.Type = MsgTypeTemplate
+	}
+	record := db.MessageRecord{
+		RequestID:  msg.RequestID,
+		MsgType:    msg.Type,
+		AppID:      msg.AppID,
+		Mobile:     msg.Mobile,
+		TemplateID: msg.TemplateID,
+		Attempts:   msg.RetryCount + 1,
+	}
 
-	if config.IsMobileBlocked(msg.Mobile) || !config.IsMobileAllowed(msg.Mobile) {
+	if msg.Type == MsgTypeTemplate && config.Conf.Template.Normalize {
+		if err := normalizeTemplateData(&msg, &record); err != nil {
+			logger.Warnf("[worker-%d] 模板内容规范化失败，按原内容发送: %v", id, err)
+		} else if record.Normalized != "" {
+			logger.Infof("[worker-%d] 模板内容已规范化，request_id: %s，修改: %s", id, msg.RequestID, record.Normalized)
+		}
+	}
+
+	if msg.Mobile != "" && (config.IsMobileBlocked(msg.Mobile) || !config.IsMobileAllowed(msg.Mobile)) {
 		logger.Warnf("[worker-%d] 手机号 %s 被过滤，跳过", id, msg.Mobile)
 		return
 	}
 
-	openid, err := vxmsg.GetUserOpenIDByMobile(msg.Mobile)
+	openid, err := resolveOpenID(&msg)
 	if err != nil {
 		logger.Errorf("[worker-%d] 获取 OpenID 失败: %v", id, err)
 		AddFailWithReason("geterror_openid", msg.AppID)
@@ -177,24 +238,46 @@ func processMessage(rdb *redis.Client, raw string, id int) {
 
 		statChan <- statTask{Type: "user_stat", Mobile: msg.Mobile, OpenID: openid, AppID: msg.AppID, OK: false}
 		statChan <- statTask{Type: "push_stat", Time: time.Now(), AppID: msg.AppID, OK: false}
+		record.Status, record.ErrMsg = db.MsgStatusFailed, err.Error()
+		statChan <- statTask{Type: "message", Record: record}
 		return
 	}
+	record.OpenID = openid
 
-	tpl := vxmsg.TemplateMsg{
-		ToUser:      openid,
-		TemplateID:  msg.TemplateID,
-		URL:         msg.URL,
-		Data:        msg.Data,
-		MiniProgram: msg.MiniProgram,
+	// 已知取消关注的用户不再调用微信接口，避免 43004 反复重试
+	if unsubscribed, err := db.IsFollowerUnsubscribed(msg.AppID, openid); err != nil {
+		logger.Warnf("[worker-%d] 查询关注状态失败，继续发送: %v", id, err)
+	} else if unsubscribed {
+		logger.Warnf("[worker-%d] 用户 %s 已取消关注，跳过", id, openid)
+		if msg.RetryCount == 0 {
+			// 重试中的消息已在首次失败时计数
+			AddFailWithReason("unsubscribed", msg.AppID)
+			statChan <- statTask{Type: "user_stat", Mobile: msg.Mobile, OpenID: openid, AppID: msg.AppID, OK: false}
+			statChan <- statTask{Type: "push_stat", Time: time.Now(), AppID: msg.AppID, OK: false}
+		}
+		record.Status, record.ErrMsg = db.MsgStatusFailed, "用户已取消关注"
+		statChan <- statTask{Type: "message", Record: record}
+		return
 	}
 
-	err = vxmsg.SendTemplateMsg(tpl)
+	msgID, err := sendMessage(&msg, openid)
 	if err != nil {
 		msg.RetryCount++
+		record.ErrMsg = err.Error()
+		if _, ok := err.(*invalidMessageError); ok {
+			logger.Errorf("[worker-%d] 消息内容无效，不再重试: %v", id, err)
+			AddFailWithReason("invalid_message", msg.AppID)
+			statChan <- statTask{Type: "push_stat", Time: time.Now(), AppID: msg.AppID, OK: false}
+			record.Status = db.MsgStatusFailed
+			statChan <- statTask{Type: "message", Record: record}
+			return
+		}
 		if we, ok := err.(*vxmsg.WechatError); ok {
 			logger.Errorf("[worker-%d] 微信发送失败 errcode=%d errmsg=%s", id, we.ErrCode, we.ErrMsg)
 			if msg.RetryCount == 1 {
-				_ = db.UpdateUserSendStatWithAppID(msg.Mobile, openid, msg.AppID, false)
+				if msg.Mobile != "" {
+					_ = db.UpdateUserSendStatWithAppID(msg.Mobile, openid, msg.AppID, false)
+				}
 				if err := db.UpdatePushStatWithAppID(time.Now(), false, msg.AppID); err != nil {
 					logger.Warnf("[worker-%d] push_stat 更新失败: %v", id, err)
 				}
@@ -203,6 +286,8 @@ func processMessage(rdb *redis.Client, raw string, id int) {
 					AddFailWithReason("invalid_openid", msg.AppID)
 				case 43004:
 					AddFailWithReason("user_not_followed", msg.AppID)
+				case 40001:
+					AddFailWithReason("invalid_token", msg.AppID)
 				case 42001:
 					AddFailWithReason("token_expired", msg.AppID)
 				default:
@@ -210,13 +295,15 @@ func processMessage(rdb *redis.Client, raw string, id int) {
 				}
 			}
 		} else {
-			logger.Errorf("[worker-%d] 模板消息发送失败: %v", id, err)
+			logger.Errorf("[worker-%d] %s 消息发送失败: %v", id, msg.Type, err)
 			if msg.RetryCount == 1 {
 				AddFailWithReason("send_error", msg.AppID)
 			}
 		}
 
 		if msg.RetryCount > maxRetryCount {
+			record.Status = db.MsgStatusFailed
+			statChan <- statTask{Type: "message", Record: record}
 			bs, _ := json.Marshal(msg)
 			if err := rdb.RPush(ctx, deadLetterQueue, bs).Err(); err != nil {
 				logger.Errorf("[worker-%d] 死信入队失败: %v", id, err)
@@ -226,6 +313,8 @@ func processMessage(rdb *redis.Client, raw string, id int) {
 			return
 		}
 
+		record.Status = db.MsgStatusRetrying
+		statChan <- statTask{Type: "message", Record: record}
 		bs, _ := json.Marshal(msg)
 		delay := msg.RetryCount * delayStepSeconds
 		score := float64(time.Now().Add(time.Duration(delay) * time.Second).Unix())
@@ -244,6 +333,85 @@ func processMessage(rdb *redis.Client, raw string, id int) {
 	statChan <- statTask{Type: "push_stat", Time: time.Now(), AppID: msg.AppID, OK: true}
 	statChan <- statTask{Type: "user_stat", Mobile: msg.Mobile, OpenID: openid, AppID: msg.AppID, OK: true}
 	statChan <- statTask{Type: "openid", Mobile: msg.Mobile, OpenID: openid, AppID: msg.AppID}
-	logger.Infof("[worker-%d] 模板消息发送成功: %s", id, openid)
+	record.Status, record.MsgID = db.MsgStatusSent, msgID
+	statChan <- statTask{Type: "message", Record: record}
+	logger.Infof("[worker-%d] %s 消息发送成功: %s，request_id: %s，msgid: %d", id, msg.Type, openid, msg.RequestID, msgID)
+
+}
+
+// resolveOpenID 客服消息优先使用指定的 openid，否则按手机号查询
+func resolveOpenID(msg *RedisMessage) (string, error) {
+	if msg.Type == MsgTypeCustom && msg.Custom != nil && msg.Custom.ToUser != "" {
+		return msg.Custom.ToUser, nil
+	}
+	return vxmsg.GetUserOpenIDByMobile(msg.Mobile)
+}
 
+// normalizeTemplateData 按 MySQL 中缓存的模板字段规范化模板消息内容，并在投递记录中记下修改的字段；
+// 模板未缓存时不处理。重试时内容已规范化，不会重复记录。
+func normalizeTemplateData(msg *RedisMessage, record *db.MessageRecord) error {
+	t, err := db.GetTemplate(msg.AppID, msg.TemplateID)
+	if err != nil || t == nil {
+		return err
+	}
+	var keywords []vxmsg.TemplateKeyword
+	if err := json.Unmarshal([]byte(t.Keywords), &keywords); err != nil {
+		return fmt.Errorf("解析模板字段失败: %v", err)
+	}
+
+	data, changes := vxmsg.NormalizeTemplateData(keywords, msg.Data)
+	if len(changes) == 0 {
+		return nil
+	}
+	bs, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	record.Normalized = string(bs)
+	return nil
+}
+
+// sendMessage 按消息类型调用微信接口，返回模板消息的 msgid（其他类型为 0）
+func sendMessage(msg *RedisMessage, openid string) (int64, error) {
+	client := vxmsg.DefaultClient(msg.AppID)
+	switch msg.Type {
+	case MsgTypeTemplate:
+		tpl := vxmsg.TemplateMsg{
+			ToUser:      openid,
+			TemplateID:  msg.TemplateID,
+			URL:         msg.URL,
+			Data:        msg.Data,
+			MiniProgram: msg.MiniProgram,
+		}
+		result, err := client.SendTemplate(ctx, tpl)
+		if err != nil {
+			return 0, err
+		}
+		return result.MsgID, nil
+	case MsgTypeSubscribe:
+		sub := vxmsg.SubscribeMsg{
+			ToUser:      openid,
+			TemplateID:  msg.TemplateID,
+			Page:        msg.Page,
+			MiniProgram: msg.MiniProgram,
+			Data:        msg.Data,
+		}
+		result, err := client.SendSubscribe(ctx, sub)
+		if err != nil {
+			return 0, err
+		}
+		return result.MsgID, nil
+	case MsgTypeCustom:
+		if msg.Custom == nil {
+			return 0, &invalidMessageError{fmt.Errorf("缺少客服消息内容")}
+		}
+		custom := *msg.Custom
+		custom.ToUser = openid
+		if err := custom.Validate(); err != nil {
+			return 0, &invalidMessageError{err}
+		}
+		return 0, client.SendCustom(ctx, custom)
+	}
+	return 0, &invalidMessageError{fmt.Errorf("不支持的消息类型: %s", msg.Type)}
 }
@@ -30,6 +30,13 @@ var (
 		},
 		[]string{"reason"},
 	)
+	deliveryCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "push_delivery_total",
+			Help: "Total number of template messages by final delivery status reported by WeChat",
+		},
+		[]string{"status"},
+	)
 )
 
 // 日志用的每分钟计数（独立于 Prometheus）
@@ -77,11 +84,17 @@ func AddFailWithReason(reason string, appid string) {
 	failReasonLogCounter.m[label]++
 }
 
+// AddDelivery 记录微信回调的模板消息最终送达状态（Prometheus）
+func AddDelivery(status string) {
+	deliveryCounter.WithLabelValues(status).Inc()
+}
+
 func init() {
 	// 注册 Prometheus 指标
 	prometheus.MustRegister(successCounter)
 	prometheus.MustRegister(failCounter)
 	prometheus.MustRegister(failByReasonCounter)
+	prometheus.MustRegister(deliveryCounter)
 }
 
 // StartStatRecorder 启动统计协程，每分钟写一次日志
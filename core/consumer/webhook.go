@@ -0,0 +1,246 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"vxmsgpush/config"
+	"vxmsgpush/core/vxmsg"
+	"vxmsgpush/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	webhookQueue           = "wx_webhook_queue" // 回调转发队列
+	webhookDelayQueue      = "wx_webhook_delay" // 转发重试延迟队列（ZSet）
+	webhookDeadLetterQueue = "wx_webhook_dlq"   // 转发死信队列
+	webhookDelayStep       = 10                 // 每次重试递增秒数
+	defaultWebhookTimeout  = 3
+	defaultWebhookRetry    = 5
+)
+
+var webhookCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "webhook_delivery_total",
+		Help: "Total number of webhook deliveries by webhook name and result",
+	},
+	[]string{"webhook", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(webhookCounter)
+}
+
+// webhookClient 转发使用的 HTTP 客户端，超时由每个订阅单独控制
+var webhookClient = &http.Client{}
+
+// webhookJob 转发队列中的任务，投递时按名称查找订阅配置
+type webhookJob struct {
+	Webhook    string          `json:"webhook"`
+	ID         string          `json:"id"`
+	Payload    json.RawMessage `json:"payload"`
+	RetryCount int             `json:"retry_count,omitempty"`
+}
+
+// webhookPayload 转发给下游的 JSON 内容
+type webhookPayload struct {
+	ID         string                 `json:"id"` // 消息 ID，下游可据此去重
+	AppID      string                 `json:"appid"`
+	ReceivedAt int64                  `json:"received_at"`
+	Message    *vxmsg.CallbackMessage `json:"message"`
+}
+
+// EnqueueWebhooks 将回调消息写入转发队列（每个匹配的订阅一条），由 worker 异步投递，不占用微信回复时间；
+// 有订阅入队失败时返回错误，调用方应让微信重试（已入队的订阅可能收到重复转发，可按 id 去重）
+func EnqueueWebhooks(appid string, msg *vxmsg.CallbackMessage) error {
+	var matched []config.WebhookConfig
+	for _, w := range config.Conf.Webhooks {
+		if matchWebhook(w, appid, msg) {
+			matched = append(matched, w)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	id := msg.DedupID()
+	payload, err := json.Marshal(webhookPayload{ID: id, AppID: appid, ReceivedAt: time.Now().Unix(), Message: msg})
+	if err != nil {
+		logger.Errorf("[webhook] 序列化转发内容失败: %v", err)
+		return nil // 重试也无法序列化
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var lastErr error
+	for _, w := range matched {
+		bs, _ := json.Marshal(webhookJob{Webhook: w.Name, ID: id, Payload: payload})
+		if err := RDB.RPush(ctx, webhookQueue, bs).Err(); err != nil {
+			logger.Errorf("[webhook] 转发任务入队失败，订阅: %s，id: %s: %v", w.Name, id, err)
+			lastErr = fmt.Errorf("转发任务入队失败，订阅: %s: %v", w.Name, err)
+		}
+	}
+	return lastErr
+}
+
+// matchWebhook 判断订阅是否匹配该消息
+func matchWebhook(w config.WebhookConfig, appid string, msg *vxmsg.CallbackMessage) bool {
+	if len(w.AppIDs) > 0 && !matchAppID(w.AppIDs, appid) {
+		return false
+	}
+	if len(w.MsgTypes) == 0 && len(w.Events) == 0 {
+		return true
+	}
+	if msg.MsgType == vxmsg.MsgTypeEvent {
+		return contains(w.Events, msg.Event)
+	}
+	return contains(w.MsgTypes, msg.MsgType)
+}
+
+// matchAppID 判断 appid 是否在订阅的公众号中，默认公众号写为 "" 或 vxkey.appid 均可
+func matchAppID(appids []string, appid string) bool {
+	for _, a := range appids {
+		if config.NormalizeAppID(a) == appid {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// StartWebhookWorkers 启动回调转发 worker 及其延迟队列调度器
+func StartWebhookWorkers(rdb *redis.Client, workerCount int) {
+	for i := 0; i < workerCount; i++ {
+		go func(id int) {
+			for {
+				result, err := rdb.BRPop(ctx, 5*time.Second, webhookQueue).Result()
+				if err == redis.Nil || len(result) < 2 {
+					continue
+				}
+				if err != nil {
+					logger.Errorf("[webhook-%d] Redis BRPop 错误: %v", id, err)
+					time.Sleep(time.Second)
+					continue
+				}
+				processWebhookJob(rdb, result[1], id)
+			}
+		}(i + 1)
+	}
+	StartRetryScheduler(rdb, webhookDelayQueue, webhookQueue, 30)
+
+	logger.Infof("[webhook] 启动 %d 个转发 worker，订阅 %d 个", workerCount, len(config.Conf.Webhooks))
+}
+
+func processWebhookJob(rdb *redis.Client, raw string, id int) {
+	var job webhookJob
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		logger.Errorf("[webhook-%d] JSON 解析失败: %v，内容: %s", id, err, raw)
+		return
+	}
+
+	w, ok := findWebhook(job.Webhook)
+	if !ok {
+		logger.Warnf("[webhook-%d] 订阅 %s 已不存在，丢弃任务 %s", id, job.Webhook, job.ID)
+		return
+	}
+
+	err := deliverWebhook(w, job.ID, job.Payload)
+	if err == nil {
+		webhookCounter.WithLabelValues(w.Name, "success").Inc()
+		logger.Infof("[webhook-%d] 转发成功，订阅: %s，id: %s", id, w.Name, job.ID)
+		return
+	}
+	webhookCounter.WithLabelValues(w.Name, "fail").Inc()
+
+	job.RetryCount++
+	maxRetry := w.MaxRetry
+	if maxRetry <= 0 {
+		maxRetry = defaultWebhookRetry
+	}
+	bs, _ := json.Marshal(job)
+	if job.RetryCount > maxRetry {
+		if err := rdb.RPush(ctx, webhookDeadLetterQueue, bs).Err(); err != nil {
+			logger.Errorf("[webhook-%d] 死信入队失败: %v", id, err)
+		} else {
+			logger.Warnf("[webhook-%d] 转发失败进入死信队列，订阅: %s，id: %s: %v", id, w.Name, job.ID, err)
+		}
+		return
+	}
+
+	delay := job.RetryCount * webhookDelayStep
+	score := float64(time.Now().Add(time.Duration(delay) * time.Second).Unix())
+	if err := rdb.ZAdd(ctx, webhookDelayQueue, redis.Z{Score: score, Member: bs}).Err(); err != nil {
+		logger.Errorf("[webhook-%d] 延迟入队失败: %v", id, err)
+	} else {
+		logger.Warnf("[webhook-%d] 转发失败，%ds 后第 %d 次重试，订阅: %s，id: %s: %v", id, delay, job.RetryCount, w.Name, job.ID, err)
+	}
+}
+
+func findWebhook(name string) (config.WebhookConfig, bool) {
+	for _, w := range config.Conf.Webhooks {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return config.WebhookConfig{}, false
+}
+
+// deliverWebhook 以签名 JSON POST 到订阅地址，非 2xx 视为失败。
+// 签名为 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制，放在 X-Webhook-Signature 头中。
+func deliverWebhook(w config.WebhookConfig, id string, payload []byte) error {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("构造请求失败: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", id)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", WebhookSignature(w.Secret, timestamp, payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("响应状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// WebhookSignature 计算转发签名，下游按相同方式校验
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"vxmsgpush/logger"
+)
+
+// 自动回复规则类型
+const (
+	RuleTypeKeyword   = "keyword"   // 关键词回复
+	RuleTypeSubscribe = "subscribe" // 关注回复
+	RuleTypeDefault   = "default"   // 收到消息时的默认回复
+)
+
+// AutoReplyRule 自动回复规则，appid 为空表示默认公众号
+type AutoReplyRule struct {
+	ID           int64     `json:"id"`
+	AppID        string    `json:"appid"`
+	RuleType     string    `json:"rule_type"`
+	MatchType    string    `json:"match_type"` // 关键词匹配方式：exact / prefix / regex
+	Keyword      string    `json:"keyword"`
+	ReplyType    string    `json:"reply_type"`    // text / image / news
+	ReplyContent string    `json:"reply_content"` // 文本内容、图片 media_id 或图文 JSON 数组
+	Priority     int       `json:"priority"`      // 越大越先匹配
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+const createAutoReplyRuleTable = `
+	CREATE TABLE IF NOT EXISTS push_autoreply_rule (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		rule_type VARCHAR(16) NOT NULL,
+		match_type VARCHAR(16) NOT NULL DEFAULT '',
+		keyword VARCHAR(255) NOT NULL DEFAULT '',
+		reply_type VARCHAR(16) NOT NULL,
+		reply_content TEXT NOT NULL,
+		priority INT NOT NULL DEFAULT 0,
+		enabled TINYINT(1) NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		KEY idx_appid (appid)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+const autoReplyRuleColumns = `id, appid, rule_type, match_type, keyword, reply_type, reply_content, priority, enabled, created_at, updated_at`
+
+func scanAutoReplyRule(row interface{ Scan(...interface{}) error }) (*AutoReplyRule, error) {
+	var r AutoReplyRule
+	err := row.Scan(&r.ID, &r.AppID, &r.RuleType, &r.MatchType, &r.Keyword, &r.ReplyType, &r.ReplyContent,
+		&r.Priority, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListAutoReplyRules 查询公众号的自动回复规则，按优先级从高到低排序；onlyEnabled 为 true 时只返回启用的规则
+func ListAutoReplyRules(appid string, onlyEnabled bool) ([]*AutoReplyRule, error) {
+	query := `SELECT ` + autoReplyRuleColumns + ` FROM push_autoreply_rule WHERE appid = ?`
+	if onlyEnabled {
+		query += ` AND enabled = 1`
+	}
+	query += ` ORDER BY priority DESC, id ASC`
+
+	rows, err := DB.Query(query, appid)
+	if err != nil {
+		logger.Errorf("[mysql] 查询自动回复规则失败: appid=%s err=%v", appid, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*AutoReplyRule
+	for rows.Next() {
+		r, err := scanAutoReplyRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetAutoReplyRule 按 ID 查询规则，不存在时返回 nil
+func GetAutoReplyRule(id int64) (*AutoReplyRule, error) {
+	r, err := scanAutoReplyRule(DB.QueryRow(`SELECT `+autoReplyRuleColumns+` FROM push_autoreply_rule WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// CreateAutoReplyRule 新增规则，返回规则 ID
+func CreateAutoReplyRule(r *AutoReplyRule) (int64, error) {
+	now := time.Now()
+	res, err := DB.Exec(`
+		INSERT INTO push_autoreply_rule
+			(appid, rule_type, match_type, keyword, reply_type, reply_content, priority, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.AppID, r.RuleType, r.MatchType, r.Keyword, r.ReplyType, r.ReplyContent, r.Priority, r.Enabled, now, now)
+	if err != nil {
+		logger.Errorf("[mysql] 新增自动回复规则失败: %v", err)
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateAutoReplyRule 按 ID 更新规则
+func UpdateAutoReplyRule(r *AutoReplyRule) error {
+	_, err := DB.Exec(`
+		UPDATE push_autoreply_rule
+		SET rule_type = ?, match_type = ?, keyword = ?, reply_type = ?, reply_content = ?, priority = ?, enabled = ?, updated_at = ?
+		WHERE id = ? AND appid = ?
+	`, r.RuleType, r.MatchType, r.Keyword, r.ReplyType, r.ReplyContent, r.Priority, r.Enabled, time.Now(), r.ID, r.AppID)
+	if err != nil {
+		logger.Errorf("[mysql] 更新自动回复规则失败: id=%d err=%v", r.ID, err)
+	}
+	return err
+}
+
+// DeleteAutoReplyRule 删除规则，返回是否存在该规则
+func DeleteAutoReplyRule(appid string, id int64) (bool, error) {
+	res, err := DB.Exec(`DELETE FROM push_autoreply_rule WHERE id = ? AND appid = ?`, id, appid)
+	if err != nil {
+		logger.Errorf("[mysql] 删除自动回复规则失败: id=%d err=%v", id, err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"time"
+	"vxmsgpush/logger"
+)
+
+// 模板消息最终送达结果，由 TEMPLATESENDJOBFINISH 回调写入，按 appid + msgid 与 push_message_record 关联
+// （msgid 只在单个公众号内唯一）
+const createTemplateDeliveryTable = `
+	CREATE TABLE IF NOT EXISTS push_template_delivery (
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		msgid BIGINT NOT NULL,
+		openid VARCHAR(100) NOT NULL DEFAULT '',
+		status VARCHAR(64) NOT NULL,
+		event_time DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (appid, msgid)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+// SaveTemplateDelivery 记录公众号模板消息的最终送达状态（微信重复推送时覆盖），appid 为空表示默认公众号
+func SaveTemplateDelivery(appid string, msgID int64, openid, status string, eventTime time.Time) error {
+	_, err := DB.Exec(`
+		INSERT INTO push_template_delivery (appid, msgid, openid, status, event_time, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE status = VALUES(status), event_time = VALUES(event_time)
+	`, appid, msgID, openid, status, eventTime, time.Now())
+	if err != nil {
+		logger.Errorf("[mysql] 保存模板消息送达状态失败: appid=%s msgid=%d status=%s err=%v", appid, msgID, status, err)
+		return err
+	}
+	logger.Infof("[mysql] 模板消息送达状态已记录: appid=%s msgid=%d openid=%s status=%s", appid, msgID, openid, status)
+	return nil
+}
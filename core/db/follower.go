@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"vxmsgpush/logger"
+)
+
+// 关注用户表，由 subscribe / unsubscribe 回调维护，appid 为空表示默认公众号（与消息队列中的 appid 一致）
+const createFollowerTable = `
+	CREATE TABLE IF NOT EXISTS push_follower (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		openid VARCHAR(100) NOT NULL,
+		subscribed TINYINT(1) NOT NULL,
+		scene VARCHAR(128) NOT NULL DEFAULT '',
+		subscribe_time DATETIME NULL,
+		unsubscribe_time DATETIME NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE KEY uniq_appid_openid (appid, openid)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+// SaveFollowerSubscribe 记录用户关注，scene 为扫码关注时的二维码参数
+func SaveFollowerSubscribe(appid, openid, scene string, ts time.Time) error {
+	_, err := DB.Exec(`
+		INSERT INTO push_follower (appid, openid, subscribed, scene, subscribe_time, updated_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE subscribed = 1, scene = VALUES(scene),
+			subscribe_time = VALUES(subscribe_time), updated_at = VALUES(updated_at)
+	`, appid, openid, scene, ts, time.Now())
+	if err != nil {
+		logger.Errorf("[mysql] 记录用户关注失败: appid=%s openid=%s err=%v", appid, openid, err)
+		return err
+	}
+	logger.Infof("[mysql] 用户关注: appid=%s openid=%s scene=%s", appid, openid, scene)
+	return nil
+}
+
+// SaveFollowerUnsubscribe 记录用户取消关注
+func SaveFollowerUnsubscribe(appid, openid string, ts time.Time) error {
+	_, err := DB.Exec(`
+		INSERT INTO push_follower (appid, openid, subscribed, unsubscribe_time, updated_at)
+		VALUES (?, ?, 0, ?, ?)
+		ON DUPLICATE KEY UPDATE subscribed = 0,
+			unsubscribe_time = VALUES(unsubscribe_time), updated_at = VALUES(updated_at)
+	`, appid, openid, ts, time.Now())
+	if err != nil {
+		logger.Errorf("[mysql] 记录用户取消关注失败: appid=%s openid=%s err=%v", appid, openid, err)
+		return err
+	}
+	logger.Infof("[mysql] 用户取消关注: appid=%s openid=%s", appid, openid)
+	return nil
+}
+
+// IsFollowerUnsubscribed 判断用户是否已知取消关注；没有记录的用户视为可发送
+func IsFollowerUnsubscribed(appid, openid string) (bool, error) {
+	var subscribed bool
+	err := DB.QueryRow(
+		`SELECT subscribed FROM push_follower WHERE appid = ? AND openid = ?`, appid, openid,
+	).Scan(&subscribed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !subscribed, nil
+}
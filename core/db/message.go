@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"vxmsgpush/logger"
+)
+
+// 消息记录状态
+const (
+	MsgStatusSent     = "sent"     // 微信接口已受理
+	MsgStatusRetrying = "retrying" // 发送失败，等待重试
+	MsgStatusFailed   = "failed"   // 发送失败且不再重试
+)
+
+// MessageRecord 单条消息的投递记录，以请求 ID 唯一标识
+type MessageRecord struct {
+	RequestID  string
+	MsgType    string // template / custom
+	AppID      string
+	Mobile     string
+	OpenID     string
+	TemplateID string
+	MsgID      int64
+	Status     string
+	Attempts   int
+	ErrMsg     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Normalized string // 发送前规范化修改的字段（JSON 数组），未修改时为空
+
+	DeliveryStatus string // 微信回调的最终送达状态，尚未回调时为空
+}
+
+const createMessageRecordTable = `
+	CREATE TABLE IF NOT EXISTS push_message_record (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		request_id VARCHAR(64) NOT NULL,
+		msg_type VARCHAR(32) NOT NULL DEFAULT 'template',
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		mobile VARCHAR(20) NOT NULL,
+		openid VARCHAR(100) NOT NULL DEFAULT '',
+		template_id VARCHAR(128) NOT NULL DEFAULT '',
+		msgid BIGINT NOT NULL DEFAULT 0,
+		status VARCHAR(32) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		err_msg VARCHAR(255) NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		normalized TEXT NULL,
+		UNIQUE KEY uniq_request_id (request_id),
+		KEY idx_msgid (msgid),
+		KEY idx_mobile (mobile)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+// SaveMessageRecord 写入或更新消息投递记录（按 request_id 去重）
+func SaveMessageRecord(rec MessageRecord) error {
+	now := time.Now()
+	_, err := DB.Exec(`
+		INSERT INTO push_message_record
+			(request_id, msg_type, appid, mobile, openid, template_id, msgid, status, attempts, err_msg, normalized, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			openid = IF(VALUES(openid) = '', openid, VALUES(openid)),
+			msgid = IF(VALUES(msgid) = 0, msgid, VALUES(msgid)),
+			status = VALUES(status),
+			attempts = VALUES(attempts),
+			err_msg = VALUES(err_msg),
+			normalized = IF(VALUES(normalized) = '', normalized, VALUES(normalized)),
+			updated_at = VALUES(updated_at)
+	`, rec.RequestID, rec.MsgType, rec.AppID, rec.Mobile, rec.OpenID, rec.TemplateID, rec.MsgID,
+		rec.Status, rec.Attempts, truncate(rec.ErrMsg, 255), rec.Normalized, now, now)
+	if err != nil {
+		logger.Errorf("[mysql] 保存消息记录失败: request_id=%s status=%s err=%v", rec.RequestID, rec.Status, err)
+		return err
+	}
+	return nil
+}
+
+// GetMessageRecord 按请求 ID 查询消息投递记录（含最终送达状态），不存在时返回 nil
+func GetMessageRecord(requestID string) (*MessageRecord, error) {
+	var rec MessageRecord
+	err := DB.QueryRow(`
+		SELECT r.request_id, r.msg_type, r.appid, r.mobile, r.openid, r.template_id, r.msgid, r.status, r.attempts, r.err_msg,
+			IFNULL(r.normalized, ''), r.created_at, r.updated_at, IFNULL(d.status, '')
+		FROM push_message_record r
+		LEFT JOIN push_template_delivery d
+			ON r.msg_type = 'template' AND r.msgid <> 0 AND d.appid = r.appid AND d.msgid = r.msgid
+		WHERE r.request_id = ?
+	`, requestID).Scan(&rec.RequestID, &rec.MsgType, &rec.AppID, &rec.Mobile, &rec.OpenID, &rec.TemplateID, &rec.MsgID,
+		&rec.Status, &rec.Attempts, &rec.ErrMsg, &rec.Normalized, &rec.CreatedAt, &rec.UpdatedAt, &rec.DeliveryStatus)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// truncate 按字符截断，避免超出字段长度
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -74,7 +74,7 @@ func InitMySQL() error {
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 	`
 
-	tables := []string{createStatTable, createReasonTable, createUserStatTable}
+	tables := []string{createStatTable, createReasonTable, createUserStatTable, createMessageRecordTable, createTemplateDeliveryTable, createFollowerTable, createAutoReplyRuleTable, createTemplateTable, createNoticeTemplateTable}
 	for _, sqlStmt := range tables {
 		if _, err := DB.Exec(sqlStmt); err != nil {
 			logger.Errorf("[mysql] 创建表失败: %v", err)
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"vxmsgpush/logger"
+)
+
+// NoticeTemplate 通知模板：按名称将业务变量渲染为模板消息 / 订阅通知，appid 为空表示默认公众号。
+// Fields 为 JSON 对象，key 为模板字段（如 thing1），value 为 text/template 表达式；
+// URL、Page 与小程序页面路径同样支持 text/template 表达式。
+type NoticeTemplate struct {
+	ID                  int64     `json:"id"`
+	AppID               string    `json:"appid"`
+	Name                string    `json:"name"`
+	Kind                string    `json:"kind"` // template / subscribe
+	TemplateID          string    `json:"template_id"`
+	Fields              string    `json:"fields"`
+	URL                 string    `json:"url"`
+	Page                string    `json:"page"`
+	MiniProgramAppID    string    `json:"miniprogram_appid"`
+	MiniProgramPagePath string    `json:"miniprogram_pagepath"`
+	Description         string    `json:"description"`
+	Enabled             bool      `json:"enabled"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+const createNoticeTemplateTable = `
+	CREATE TABLE IF NOT EXISTS push_notice_template (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		name VARCHAR(64) NOT NULL,
+		kind VARCHAR(16) NOT NULL DEFAULT 'template',
+		template_id VARCHAR(128) NOT NULL,
+		fields TEXT NOT NULL,
+		url VARCHAR(1024) NOT NULL DEFAULT '',
+		page VARCHAR(1024) NOT NULL DEFAULT '',
+		miniprogram_appid VARCHAR(64) NOT NULL DEFAULT '',
+		miniprogram_pagepath VARCHAR(1024) NOT NULL DEFAULT '',
+		description VARCHAR(255) NOT NULL DEFAULT '',
+		enabled TINYINT(1) NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE KEY uniq_appid_name (appid, name)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+const noticeTemplateColumns = `id, appid, name, kind, template_id, fields, url, page, miniprogram_appid, miniprogram_pagepath, description, enabled, created_at, updated_at`
+
+func scanNoticeTemplate(row interface{ Scan(...interface{}) error }) (*NoticeTemplate, error) {
+	var n NoticeTemplate
+	err := row.Scan(&n.ID, &n.AppID, &n.Name, &n.Kind, &n.TemplateID, &n.Fields, &n.URL, &n.Page,
+		&n.MiniProgramAppID, &n.MiniProgramPagePath, &n.Description, &n.Enabled, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ListNoticeTemplates 查询公众号的通知模板
+func ListNoticeTemplates(appid string) ([]*NoticeTemplate, error) {
+	rows, err := DB.Query(`SELECT `+noticeTemplateColumns+` FROM push_notice_template WHERE appid = ? ORDER BY name`, appid)
+	if err != nil {
+		logger.Errorf("[mysql] 查询通知模板失败: appid=%s err=%v", appid, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*NoticeTemplate
+	for rows.Next() {
+		n, err := scanNoticeTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// GetNoticeTemplate 按名称查询通知模板，不存在时返回 nil
+func GetNoticeTemplate(appid, name string) (*NoticeTemplate, error) {
+	n, err := scanNoticeTemplate(DB.QueryRow(
+		`SELECT `+noticeTemplateColumns+` FROM push_notice_template WHERE appid = ? AND name = ?`, appid, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return n, err
+}
+
+// CreateNoticeTemplate 新增通知模板，返回 ID；同一公众号下名称重复时返回数据库错误
+func CreateNoticeTemplate(n *NoticeTemplate) (int64, error) {
+	now := time.Now()
+	res, err := DB.Exec(`
+		INSERT INTO push_notice_template
+			(appid, name, kind, template_id, fields, url, page, miniprogram_appid, miniprogram_pagepath, description, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, n.AppID, n.Name, n.Kind, n.TemplateID, n.Fields, n.URL, n.Page, n.MiniProgramAppID, n.MiniProgramPagePath,
+		n.Description, n.Enabled, now, now)
+	if err != nil {
+		logger.Errorf("[mysql] 新增通知模板失败: name=%s err=%v", n.Name, err)
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateNoticeTemplate 按 appid 与名称更新通知模板
+func UpdateNoticeTemplate(n *NoticeTemplate) error {
+	_, err := DB.Exec(`
+		UPDATE push_notice_template
+		SET kind = ?, template_id = ?, fields = ?, url = ?, page = ?, miniprogram_appid = ?, miniprogram_pagepath = ?,
+			description = ?, enabled = ?, updated_at = ?
+		WHERE appid = ? AND name = ?
+	`, n.Kind, n.TemplateID, n.Fields, n.URL, n.Page, n.MiniProgramAppID, n.MiniProgramPagePath,
+		n.Description, n.Enabled, time.Now(), n.AppID, n.Name)
+	if err != nil {
+		logger.Errorf("[mysql] 更新通知模板失败: name=%s err=%v", n.Name, err)
+	}
+	return err
+}
+
+// DeleteNoticeTemplate 删除通知模板，返回是否存在该模板
+func DeleteNoticeTemplate(appid, name string) (bool, error) {
+	res, err := DB.Exec(`DELETE FROM push_notice_template WHERE appid = ? AND name = ?`, appid, name)
+	if err != nil {
+		logger.Errorf("[mysql] 删除通知模板失败: name=%s err=%v", name, err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
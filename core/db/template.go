@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"vxmsgpush/logger"
+)
+
+// TemplateRecord 缓存的公众号模板，Keywords 为模板字段的 JSON 数组
+type TemplateRecord struct {
+	AppID           string    `json:"appid"`
+	TemplateID      string    `json:"template_id"`
+	Title           string    `json:"title"`
+	PrimaryIndustry string    `json:"primary_industry"`
+	DeputyIndustry  string    `json:"deputy_industry"`
+	Content         string    `json:"content"`
+	Example         string    `json:"example"`
+	Keywords        string    `json:"keywords"`
+	SyncedAt        time.Time `json:"synced_at"`
+}
+
+const createTemplateTable = `
+	CREATE TABLE IF NOT EXISTS push_template (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		appid VARCHAR(64) NOT NULL DEFAULT '',
+		template_id VARCHAR(128) NOT NULL,
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		primary_industry VARCHAR(64) NOT NULL DEFAULT '',
+		deputy_industry VARCHAR(64) NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		example TEXT NOT NULL,
+		keywords TEXT NOT NULL,
+		synced_at DATETIME NOT NULL,
+		UNIQUE KEY uniq_appid_template (appid, template_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+const templateColumns = `appid, template_id, title, primary_industry, deputy_industry, content, example, keywords, synced_at`
+
+func scanTemplate(row interface{ Scan(...interface{}) error }) (*TemplateRecord, error) {
+	var t TemplateRecord
+	err := row.Scan(&t.AppID, &t.TemplateID, &t.Title, &t.PrimaryIndustry, &t.DeputyIndustry,
+		&t.Content, &t.Example, &t.Keywords, &t.SyncedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ReplaceTemplates 用微信返回的模板列表整体替换公众号的模板缓存
+func ReplaceTemplates(appid string, templates []TemplateRecord) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		logger.Errorf("[mysql] 开启事务失败: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM push_template WHERE appid = ?`, appid); err != nil {
+		logger.Errorf("[mysql] 清理模板缓存失败: appid=%s err=%v", appid, err)
+		return err
+	}
+	now := time.Now()
+	for _, t := range templates {
+		if _, err := tx.Exec(`
+			INSERT INTO push_template (`+templateColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, appid, t.TemplateID, t.Title, t.PrimaryIndustry, t.DeputyIndustry, t.Content, t.Example, t.Keywords, now); err != nil {
+			logger.Errorf("[mysql] 写入模板缓存失败: appid=%s template_id=%s err=%v", appid, t.TemplateID, err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Errorf("[mysql] 提交事务失败: %v", err)
+		return err
+	}
+	logger.Infof("[mysql] 模板缓存已更新: appid=%s 共 %d 个", appid, len(templates))
+	return nil
+}
+
+// ListTemplates 查询公众号缓存的模板
+func ListTemplates(appid string) ([]*TemplateRecord, error) {
+	rows, err := DB.Query(`SELECT `+templateColumns+` FROM push_template WHERE appid = ? ORDER BY id`, appid)
+	if err != nil {
+		logger.Errorf("[mysql] 查询模板缓存失败: appid=%s err=%v", appid, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*TemplateRecord
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// GetTemplate 查询缓存的单个模板，不存在时返回 nil
+func GetTemplate(appid, templateID string) (*TemplateRecord, error) {
+	t, err := scanTemplate(DB.QueryRow(
+		`SELECT `+templateColumns+` FROM push_template WHERE appid = ? AND template_id = ?`, appid, templateID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// DeleteTemplate 删除缓存的模板
+func DeleteTemplate(appid, templateID string) error {
+	_, err := DB.Exec(`DELETE FROM push_template WHERE appid = ? AND template_id = ?`, appid, templateID)
+	if err != nil {
+		logger.Errorf("[mysql] 删除模板缓存失败: appid=%s template_id=%s err=%v", appid, templateID, err)
+	}
+	return err
+}
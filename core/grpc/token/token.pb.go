@@ -23,6 +23,7 @@ const (
 
 type TokenRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Appid         string                 `protobuf:"bytes,1,opt,name=appid,proto3" json:"appid,omitempty"` // 公众号 AppID，为空时使用默认公众号
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -57,10 +58,18 @@ func (*TokenRequest) Descriptor() ([]byte, []int) {
 	return file_proto_token_proto_rawDescGZIP(), []int{0}
 }
 
+func (x *TokenRequest) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
 type TokenReply struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	AccessToken   string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
 	ExpireAt      int64                  `protobuf:"varint,2,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"` // 过期时间戳（秒）
+	Appid         string                 `protobuf:"bytes,3,opt,name=appid,proto3" json:"appid,omitempty"`                        // 实际使用的公众号 AppID
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -109,18 +118,361 @@ func (x *TokenReply) GetExpireAt() int64 {
 	return 0
 }
 
+func (x *TokenReply) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+type RefreshRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Appid         string                 `protobuf:"bytes,1,opt,name=appid,proto3" json:"appid,omitempty"`                             // 公众号 AppID，为空时使用默认公众号
+	StaleToken    string                 `protobuf:"bytes,2,opt,name=stale_token,json=staleToken,proto3" json:"stale_token,omitempty"` // 调用方认为已失效的 token，服务端 token 已更新时直接返回新 token
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshRequest) Reset() {
+	*x = RefreshRequest{}
+	mi := &file_proto_token_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshRequest) ProtoMessage() {}
+
+func (x *RefreshRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_token_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshRequest.ProtoReflect.Descriptor instead.
+func (*RefreshRequest) Descriptor() ([]byte, []int) {
+	return file_proto_token_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RefreshRequest) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+func (x *RefreshRequest) GetStaleToken() string {
+	if x != nil {
+		return x.StaleToken
+	}
+	return ""
+}
+
+type TicketRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Appid         string                 `protobuf:"bytes,1,opt,name=appid,proto3" json:"appid,omitempty"` // 公众号 AppID，为空时使用默认公众号
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`   // jsapi（默认）| wx_card
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TicketRequest) Reset() {
+	*x = TicketRequest{}
+	mi := &file_proto_token_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TicketRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TicketRequest) ProtoMessage() {}
+
+func (x *TicketRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_token_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TicketRequest.ProtoReflect.Descriptor instead.
+func (*TicketRequest) Descriptor() ([]byte, []int) {
+	return file_proto_token_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TicketRequest) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+func (x *TicketRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type TicketReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ticket        string                 `protobuf:"bytes,1,opt,name=ticket,proto3" json:"ticket,omitempty"`
+	ExpireAt      int64                  `protobuf:"varint,2,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"` // 过期时间戳（秒）
+	Appid         string                 `protobuf:"bytes,3,opt,name=appid,proto3" json:"appid,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TicketReply) Reset() {
+	*x = TicketReply{}
+	mi := &file_proto_token_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TicketReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TicketReply) ProtoMessage() {}
+
+func (x *TicketReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_token_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TicketReply.ProtoReflect.Descriptor instead.
+func (*TicketReply) Descriptor() ([]byte, []int) {
+	return file_proto_token_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TicketReply) GetTicket() string {
+	if x != nil {
+		return x.Ticket
+	}
+	return ""
+}
+
+func (x *TicketReply) GetExpireAt() int64 {
+	if x != nil {
+		return x.ExpireAt
+	}
+	return 0
+}
+
+func (x *TicketReply) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+func (x *TicketReply) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type SignRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Appid         string                 `protobuf:"bytes,1,opt,name=appid,proto3" json:"appid,omitempty"`                       // 公众号 AppID，为空时使用默认公众号
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`                           // 当前网页完整 URL（# 及其后部分会被忽略）
+	NonceStr      string                 `protobuf:"bytes,3,opt,name=nonce_str,json=nonceStr,proto3" json:"nonce_str,omitempty"` // 为空时由服务端生成
+	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`              // 为 0 时使用服务端当前时间
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignRequest) Reset() {
+	*x = SignRequest{}
+	mi := &file_proto_token_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignRequest) ProtoMessage() {}
+
+func (x *SignRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_token_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignRequest.ProtoReflect.Descriptor instead.
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return file_proto_token_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SignRequest) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+func (x *SignRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *SignRequest) GetNonceStr() string {
+	if x != nil {
+		return x.NonceStr
+	}
+	return ""
+}
+
+func (x *SignRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type SignReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Appid         string                 `protobuf:"bytes,1,opt,name=appid,proto3" json:"appid,omitempty"`
+	NonceStr      string                 `protobuf:"bytes,2,opt,name=nonce_str,json=nonceStr,proto3" json:"nonce_str,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Signature     string                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignReply) Reset() {
+	*x = SignReply{}
+	mi := &file_proto_token_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignReply) ProtoMessage() {}
+
+func (x *SignReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_token_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignReply.ProtoReflect.Descriptor instead.
+func (*SignReply) Descriptor() ([]byte, []int) {
+	return file_proto_token_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SignReply) GetAppid() string {
+	if x != nil {
+		return x.Appid
+	}
+	return ""
+}
+
+func (x *SignReply) GetNonceStr() string {
+	if x != nil {
+		return x.NonceStr
+	}
+	return ""
+}
+
+func (x *SignReply) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *SignReply) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
 var File_proto_token_proto protoreflect.FileDescriptor
 
 const file_proto_token_proto_rawDesc = "" +
 	"\n" +
-	"\x11proto/token.proto\x12\x05token\"\x0e\n" +
-	"\fTokenRequest\"L\n" +
+	"\x11proto/token.proto\x12\x05token\"$\n" +
+	"\fTokenRequest\x12\x14\n" +
+	"\x05appid\x18\x01 \x01(\tR\x05appid\"b\n" +
 	"\n" +
 	"TokenReply\x12!\n" +
 	"\faccess_token\x18\x01 \x01(\tR\vaccessToken\x12\x1b\n" +
-	"\texpire_at\x18\x02 \x01(\x03R\bexpireAt2H\n" +
+	"\texpire_at\x18\x02 \x01(\x03R\bexpireAt\x12\x14\n" +
+	"\x05appid\x18\x03 \x01(\tR\x05appid\"G\n" +
+	"\x0eRefreshRequest\x12\x14\n" +
+	"\x05appid\x18\x01 \x01(\tR\x05appid\x12\x1f\n" +
+	"\vstale_token\x18\x02 \x01(\tR\n" +
+	"staleToken\"9\n" +
+	"\rTicketRequest\x12\x14\n" +
+	"\x05appid\x18\x01 \x01(\tR\x05appid\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"l\n" +
+	"\vTicketReply\x12\x16\n" +
+	"\x06ticket\x18\x01 \x01(\tR\x06ticket\x12\x1b\n" +
+	"\texpire_at\x18\x02 \x01(\x03R\bexpireAt\x12\x14\n" +
+	"\x05appid\x18\x03 \x01(\tR\x05appid\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\"p\n" +
+	"\vSignRequest\x12\x14\n" +
+	"\x05appid\x18\x01 \x01(\tR\x05appid\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1b\n" +
+	"\tnonce_str\x18\x03 \x01(\tR\bnonceStr\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\"z\n" +
+	"\tSignReply\x12\x14\n" +
+	"\x05appid\x18\x01 \x01(\tR\x05appid\x12\x1b\n" +
+	"\tnonce_str\x18\x02 \x01(\tR\bnonceStr\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\x1c\n" +
+	"\tsignature\x18\x04 \x01(\tR\tsignature2\xb5\x02\n" +
 	"\fTokenService\x128\n" +
-	"\x0eGetAccessToken\x12\x13.token.TokenRequest\x1a\x11.token.TokenReplyB\x17Z\x15core/grpc/token;tokenb\x06proto3"
+	"\x0eGetAccessToken\x12\x13.token.TokenRequest\x1a\x11.token.TokenReply\x12>\n" +
+	"\x12RefreshAccessToken\x12\x15.token.RefreshRequest\x1a\x11.token.TokenReply\x12<\n" +
+	"\x10WatchAccessToken\x12\x13.token.TokenRequest\x1a\x11.token.TokenReply0\x01\x12:\n" +
+	"\x0eGetJsapiTicket\x12\x14.token.TicketRequest\x1a\x12.token.TicketReply\x121\n" +
+	"\tSignJsapi\x12\x12.token.SignRequest\x1a\x10.token.SignReplyB\x17Z\x15core/grpc/token;tokenb\x06proto3"
 
 var (
 	file_proto_token_proto_rawDescOnce sync.Once
@@ -134,16 +486,29 @@ func file_proto_token_proto_rawDescGZIP() []byte {
 	return file_proto_token_proto_rawDescData
 }
 
-var file_proto_token_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_token_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_proto_token_proto_goTypes = []any{
-	(*TokenRequest)(nil), // 0: token.TokenRequest
-	(*TokenReply)(nil),   // 1: token.TokenReply
+	(*TokenRequest)(nil),   // 0: token.TokenRequest
+	(*TokenReply)(nil),     // 1: token.TokenReply
+	(*RefreshRequest)(nil), // 2: token.RefreshRequest
+	(*TicketRequest)(nil),  // 3: token.TicketRequest
+	(*TicketReply)(nil),    // 4: token.TicketReply
+	(*SignRequest)(nil),    // 5: token.SignRequest
+	(*SignReply)(nil),      // 6: token.SignReply
 }
 var file_proto_token_proto_depIdxs = []int32{
 	0, // 0: token.TokenService.GetAccessToken:input_type -> token.TokenRequest
-	1, // 1: token.TokenService.GetAccessToken:output_type -> token.TokenReply
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: token.TokenService.RefreshAccessToken:input_type -> token.RefreshRequest
+	0, // 2: token.TokenService.WatchAccessToken:input_type -> token.TokenRequest
+	3, // 3: token.TokenService.GetJsapiTicket:input_type -> token.TicketRequest
+	5, // 4: token.TokenService.SignJsapi:input_type -> token.SignRequest
+	1, // 5: token.TokenService.GetAccessToken:output_type -> token.TokenReply
+	1, // 6: token.TokenService.RefreshAccessToken:output_type -> token.TokenReply
+	1, // 7: token.TokenService.WatchAccessToken:output_type -> token.TokenReply
+	4, // 8: token.TokenService.GetJsapiTicket:output_type -> token.TicketReply
+	6, // 9: token.TokenService.SignJsapi:output_type -> token.SignReply
+	5, // [5:10] is the sub-list for method output_type
+	0, // [0:5] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -160,7 +525,7 @@ func file_proto_token_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_token_proto_rawDesc), len(file_proto_token_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,11 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TokenService_GetAccessToken_FullMethodName = "/token.TokenService/GetAccessToken"
+	TokenService_GetAccessToken_FullMethodName     = "/token.TokenService/GetAccessToken"
+	TokenService_RefreshAccessToken_FullMethodName = "/token.TokenService/RefreshAccessToken"
+	TokenService_WatchAccessToken_FullMethodName   = "/token.TokenService/WatchAccessToken"
+	TokenService_GetJsapiTicket_FullMethodName     = "/token.TokenService/GetJsapiTicket"
+	TokenService_SignJsapi_FullMethodName          = "/token.TokenService/SignJsapi"
 )
 
 // TokenServiceClient is the client API for TokenService service.
@@ -27,6 +31,14 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TokenServiceClient interface {
 	GetAccessToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenReply, error)
+	// 强制刷新 access_token（用于微信返回 40001/42001 时），并发请求只会触发一次上游刷新
+	RefreshAccessToken(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*TokenReply, error)
+	// 订阅 access_token：连接后立即推送当前 token，之后每次刷新时推送新 token
+	WatchAccessToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TokenReply], error)
+	// 获取 JS-SDK jsapi_ticket 或卡券 api_ticket，票据与 access_token 一同缓存
+	GetJsapiTicket(ctx context.Context, in *TicketRequest, opts ...grpc.CallOption) (*TicketReply, error)
+	// 生成 JS-SDK wx.config 所需签名
+	SignJsapi(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignReply, error)
 }
 
 type tokenServiceClient struct {
@@ -47,11 +59,68 @@ func (c *tokenServiceClient) GetAccessToken(ctx context.Context, in *TokenReques
 	return out, nil
 }
 
+func (c *tokenServiceClient) RefreshAccessToken(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*TokenReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TokenReply)
+	err := c.cc.Invoke(ctx, TokenService_RefreshAccessToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenServiceClient) WatchAccessToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TokenReply], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TokenService_ServiceDesc.Streams[0], TokenService_WatchAccessToken_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TokenRequest, TokenReply]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TokenService_WatchAccessTokenClient = grpc.ServerStreamingClient[TokenReply]
+
+func (c *tokenServiceClient) GetJsapiTicket(ctx context.Context, in *TicketRequest, opts ...grpc.CallOption) (*TicketReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TicketReply)
+	err := c.cc.Invoke(ctx, TokenService_GetJsapiTicket_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenServiceClient) SignJsapi(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignReply)
+	err := c.cc.Invoke(ctx, TokenService_SignJsapi_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TokenServiceServer is the server API for TokenService service.
 // All implementations must embed UnimplementedTokenServiceServer
 // for forward compatibility.
 type TokenServiceServer interface {
 	GetAccessToken(context.Context, *TokenRequest) (*TokenReply, error)
+	// 强制刷新 access_token（用于微信返回 40001/42001 时），并发请求只会触发一次上游刷新
+	RefreshAccessToken(context.Context, *RefreshRequest) (*TokenReply, error)
+	// 订阅 access_token：连接后立即推送当前 token，之后每次刷新时推送新 token
+	WatchAccessToken(*TokenRequest, grpc.ServerStreamingServer[TokenReply]) error
+	// 获取 JS-SDK jsapi_ticket 或卡券 api_ticket，票据与 access_token 一同缓存
+	GetJsapiTicket(context.Context, *TicketRequest) (*TicketReply, error)
+	// 生成 JS-SDK wx.config 所需签名
+	SignJsapi(context.Context, *SignRequest) (*SignReply, error)
 	mustEmbedUnimplementedTokenServiceServer()
 }
 
@@ -65,6 +134,18 @@ type UnimplementedTokenServiceServer struct{}
 func (UnimplementedTokenServiceServer) GetAccessToken(context.Context, *TokenRequest) (*TokenReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAccessToken not implemented")
 }
+func (UnimplementedTokenServiceServer) RefreshAccessToken(context.Context, *RefreshRequest) (*TokenReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshAccessToken not implemented")
+}
+func (UnimplementedTokenServiceServer) WatchAccessToken(*TokenRequest, grpc.ServerStreamingServer[TokenReply]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAccessToken not implemented")
+}
+func (UnimplementedTokenServiceServer) GetJsapiTicket(context.Context, *TicketRequest) (*TicketReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJsapiTicket not implemented")
+}
+func (UnimplementedTokenServiceServer) SignJsapi(context.Context, *SignRequest) (*SignReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignJsapi not implemented")
+}
 func (UnimplementedTokenServiceServer) mustEmbedUnimplementedTokenServiceServer() {}
 func (UnimplementedTokenServiceServer) testEmbeddedByValue()                      {}
 
@@ -104,6 +185,71 @@ func _TokenService_GetAccessToken_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TokenService_RefreshAccessToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServiceServer).RefreshAccessToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenService_RefreshAccessToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServiceServer).RefreshAccessToken(ctx, req.(*RefreshRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenService_WatchAccessToken_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TokenRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TokenServiceServer).WatchAccessToken(m, &grpc.GenericServerStream[TokenRequest, TokenReply]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TokenService_WatchAccessTokenServer = grpc.ServerStreamingServer[TokenReply]
+
+func _TokenService_GetJsapiTicket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TicketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServiceServer).GetJsapiTicket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenService_GetJsapiTicket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServiceServer).GetJsapiTicket(ctx, req.(*TicketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenService_SignJsapi_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenServiceServer).SignJsapi(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenService_SignJsapi_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenServiceServer).SignJsapi(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TokenService_ServiceDesc is the grpc.ServiceDesc for TokenService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +261,25 @@ var TokenService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAccessToken",
 			Handler:    _TokenService_GetAccessToken_Handler,
 		},
+		{
+			MethodName: "RefreshAccessToken",
+			Handler:    _TokenService_RefreshAccessToken_Handler,
+		},
+		{
+			MethodName: "GetJsapiTicket",
+			Handler:    _TokenService_GetJsapiTicket_Handler,
+		},
+		{
+			MethodName: "SignJsapi",
+			Handler:    _TokenService_SignJsapi_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchAccessToken",
+			Handler:       _TokenService_WatchAccessToken_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/token.proto",
 }
@@ -0,0 +1,88 @@
+package vxmsg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// 微信推送的消息类型
+const (
+	MsgTypeText     = "text"
+	MsgTypeImage    = "image"
+	MsgTypeVoice    = "voice"
+	MsgTypeLocation = "location"
+	MsgTypeLink     = "link"
+	MsgTypeEvent    = "event"
+)
+
+// 微信推送的事件类型（MsgType 为 event 时的 Event 字段）
+const (
+	EventSubscribe             = "subscribe"   // 关注（扫带参数二维码关注时 EventKey 以 qrscene_ 开头）
+	EventUnsubscribe           = "unsubscribe" // 取消关注
+	EventScan                  = "SCAN"        // 已关注用户扫带参数二维码
+	EventClick                 = "CLICK"       // 点击菜单拉取消息
+	EventView                  = "VIEW"        // 点击菜单跳转链接
+	EventTemplateSendJobFinish = "TEMPLATESENDJOBFINISH"
+)
+
+// CallbackMessage 微信服务器推送到回调地址的 XML 消息，普通消息与事件共用，按 MsgType / Event 读取对应字段
+type CallbackMessage struct {
+	XMLName      xml.Name `xml:"xml" json:"-"`
+	ToUserName   string   `xml:"ToUserName" json:"to_user_name,omitempty"`     // 公众号原始 ID
+	FromUserName string   `xml:"FromUserName" json:"from_user_name,omitempty"` // 用户 openid
+	CreateTime   int64    `xml:"CreateTime" json:"create_time,omitempty"`
+	MsgType      string   `xml:"MsgType" json:"msg_type,omitempty"`
+	MsgID        int64    `xml:"MsgId" json:"msgid,omitempty"` // 普通消息 ID，事件推送没有该字段
+
+	// 文本消息
+	Content string `xml:"Content" json:"content,omitempty"`
+
+	// 图片 / 语音消息
+	PicURL      string `xml:"PicUrl" json:"pic_url,omitempty"`
+	MediaID     string `xml:"MediaId" json:"media_id,omitempty"`
+	Format      string `xml:"Format" json:"format,omitempty"`           // 语音格式，如 amr、speex
+	Recognition string `xml:"Recognition" json:"recognition,omitempty"` // 语音识别结果（需开通语音识别）
+
+	// 地理位置消息
+	LocationX float64 `xml:"Location_X" json:"location_x,omitempty"` // 纬度
+	LocationY float64 `xml:"Location_Y" json:"location_y,omitempty"` // 经度
+	Scale     int     `xml:"Scale" json:"scale,omitempty"`
+	Label     string  `xml:"Label" json:"label,omitempty"`
+
+	// 链接消息
+	Title       string `xml:"Title" json:"title,omitempty"`
+	Description string `xml:"Description" json:"description,omitempty"`
+	URL         string `xml:"Url" json:"url,omitempty"`
+
+	// 事件推送
+	Event    string `xml:"Event" json:"event,omitempty"`
+	EventKey string `xml:"EventKey" json:"event_key,omitempty"` // CLICK 为菜单 key，VIEW 为跳转链接，扫码事件为二维码参数
+	Ticket   string `xml:"Ticket" json:"ticket,omitempty"`      // 扫码事件的二维码 ticket
+
+	// 模板消息送达事件（TEMPLATESENDJOBFINISH），注意字段名为 MsgID
+	TemplateMsgID int64  `xml:"MsgID" json:"template_msg_id,omitempty"`
+	Status        string `xml:"Status" json:"status,omitempty"` // success / failed:user block / failed:system failed
+}
+
+// ParseCallbackMessage 解析微信推送的 XML 消息
+func ParseCallbackMessage(body []byte) (*CallbackMessage, error) {
+	var msg CallbackMessage
+	if err := xml.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("解析微信推送消息失败: %v", err)
+	}
+	return &msg, nil
+}
+
+// DedupID 返回消息的唯一标识，用于识别微信的重试推送：普通消息使用 MsgId，
+// 模板消息送达事件使用模板消息的 msgid，其他事件使用 FromUserName + CreateTime + Event + EventKey
+func (m *CallbackMessage) DedupID() string {
+	if m.MsgID != 0 {
+		return strconv.FormatInt(m.MsgID, 10)
+	}
+	if m.Event == EventTemplateSendJobFinish && m.TemplateMsgID != 0 {
+		// 同一用户同一秒内可能有多条模板消息送达
+		return fmt.Sprintf("%s_%d", m.Event, m.TemplateMsgID)
+	}
+	return fmt.Sprintf("%s_%d_%s_%s", m.FromUserName, m.CreateTime, m.Event, m.EventKey)
+}
@@ -0,0 +1,181 @@
+package vxmsg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"vxmsgpush/logger"
+	"vxmsgpush/utils"
+)
+
+// TokenSource 为 Client 提供 access_token
+type TokenSource interface {
+	// Token 返回当前可用的 access_token
+	Token(ctx context.Context) (string, error)
+	// Refresh 强制刷新 access_token，stale 为微信判定失效的 token
+	Refresh(ctx context.Context, stale string) (string, error)
+}
+
+// Logger Client 使用的日志接口，*logrus.Logger 即满足
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Client 微信接口客户端，所有方法支持通过 ctx 取消
+type Client struct {
+	appID      string
+	httpClient *http.Client
+	baseURL    string
+	tokens     TokenSource
+	log        Logger
+}
+
+// Option Client 构造选项
+type Option func(*Client)
+
+// WithAppID 指定公众号，未指定 TokenSource 时向 TokenService 获取该公众号的 token
+func WithAppID(appid string) Option {
+	return func(c *Client) { c.appID = appid }
+}
+
+// WithHTTPClient 指定访问微信接口使用的 HTTP 客户端
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithBaseURL 指定微信接口前缀，例如 https://api.weixin.qq.com
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimRight(baseURL, "/") }
+}
+
+// WithTokenSource 指定 access_token 来源
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) { c.tokens = ts }
+}
+
+// WithLogger 指定日志输出
+func WithLogger(l Logger) Option {
+	return func(c *Client) { c.log = l }
+}
+
+// NewClient 创建微信接口客户端，未指定的选项使用 config.Conf.Wechat 与 TokenService 的默认配置
+func NewClient(opts ...Option) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = wechatClient()
+	}
+	if c.baseURL == "" {
+		c.baseURL = wechatBaseURL()
+	}
+	if c.tokens == nil {
+		c.tokens = grpcTokenSource{appID: c.appID}
+	}
+	if c.log == nil {
+		c.log = logger.Logger
+	}
+	return c
+}
+
+// 包级函数使用的默认客户端，按 appid 缓存
+var defaultClients sync.Map
+
+// DefaultClient 返回指定公众号的默认客户端（appid 为空时使用默认公众号）
+func DefaultClient(appid string) *Client {
+	if c, ok := defaultClients.Load(appid); ok {
+		return c.(*Client)
+	}
+	c, _ := defaultClients.LoadOrStore(appid, NewClient(WithAppID(appid)))
+	return c.(*Client)
+}
+
+// withToken 获取 access_token 后执行 call；
+// 若微信返回 token 无效/过期，则强制刷新 token 并透明重试一次
+func (c *Client) withToken(ctx context.Context, call func(accessToken string) error) error {
+	accessToken, err := c.tokens.Token(ctx)
+	if err != nil {
+		c.log.Errorf("获取access_token失败: %v", err)
+		return fmt.Errorf("获取access_token失败: %v", err)
+	}
+
+	err = call(accessToken)
+	var we *WechatError
+	if !errors.As(err, &we) || !utils.IsTokenInvalid(we.ErrCode) {
+		return err
+	}
+
+	c.log.Warnf("access_token 失效（errcode=%d），AppID: %s，强制刷新后重试", we.ErrCode, c.appID)
+	newToken, refreshErr := c.tokens.Refresh(ctx, accessToken)
+	if refreshErr != nil {
+		c.log.Errorf("强制刷新access_token失败: %v", refreshErr)
+		return err
+	}
+	return call(newToken)
+}
+
+// do 调用微信接口：body 为 nil 时发送 GET，否则以 JSON POST。
+// 微信返回非 0 错误码时返回 *WechatError；out 不为 nil 时将响应解析到 out。
+func (c *Client) do(ctx context.Context, path, accessToken string, query map[string]string, body []byte, out interface{}) error {
+	params := url.Values{}
+	params.Set("access_token", accessToken)
+	for k, v := range query {
+		params.Set(k, v)
+	}
+	reqURL := c.baseURL + path + "?" + params.Encode()
+
+	method := http.MethodGet
+	var reader io.Reader
+	if body != nil {
+		method = http.MethodPost
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reader)
+	if err != nil {
+		return fmt.Errorf("构造请求失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求微信失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取微信响应失败: %v", err)
+	}
+	c.log.Debugf("微信响应: %s", string(respBody))
+
+	var result WechatError
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		c.log.Errorf("解析微信响应失败: %v", err)
+		return fmt.Errorf("解析微信响应失败: %v", err)
+	}
+	if result.ErrCode != 0 {
+		c.log.Errorf("微信返回错误: %d - %s", result.ErrCode, result.ErrMsg)
+		return &result // 返回结构化错误
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("解析微信响应失败: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package vxmsg
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vxmsgpush/config"
+	"vxmsgpush/logger"
+)
+
+const (
+	defaultBaseURL = "http://192.170.144.52:9010/weixin_api"
+	defaultTimeout = 5 * time.Second
+)
+
+var (
+	gatewayOnce   sync.Once
+	gatewayBase   string
+	gatewayClient *http.Client
+)
+
+// initGateway 根据 config.Conf.Wechat 初始化微信接口前缀与 HTTP 客户端（只执行一次）
+func initGateway() {
+	gatewayOnce.Do(func() {
+		conf := config.Conf.Wechat
+
+		gatewayBase = strings.TrimRight(conf.BaseURL, "/")
+		if gatewayBase == "" {
+			gatewayBase = defaultBaseURL
+		}
+
+		timeout := defaultTimeout
+		if conf.Timeout > 0 {
+			timeout = time.Duration(conf.Timeout) * time.Second
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if conf.Proxy != "" {
+			proxyURL, err := url.Parse(conf.Proxy)
+			if err != nil {
+				logger.Errorf("微信代理地址配置错误，忽略代理: %v", err)
+			} else {
+				transport.Proxy = http.ProxyURL(proxyURL)
+			}
+		}
+		gatewayClient = &http.Client{Timeout: timeout, Transport: transport}
+	})
+}
+
+// wechatBaseURL 返回配置的微信接口前缀
+func wechatBaseURL() string {
+	initGateway()
+	return gatewayBase
+}
+
+// wechatClient 返回访问微信接口使用的 HTTP 客户端
+func wechatClient() *http.Client {
+	initGateway()
+	return gatewayClient
+}
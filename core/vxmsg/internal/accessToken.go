@@ -2,57 +2,178 @@ package internal
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	pb "vxmsgpush/core/grpc/token"
+	"vxmsgpush/logger"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	watchMinBackoff = time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+// cachedToken 本地缓存的 access_token
+type cachedToken struct {
+	token    string
+	expireAt time.Time
+}
+
 var (
 	grpcClient pb.TokenServiceClient
 	conn       *grpc.ClientConn
 	mu         sync.Mutex
+
+	// 本地 token 缓存，key 为请求时的 appid（空字符串表示默认公众号），由 WatchAccessToken 推送更新
+	cache    = make(map[string]cachedToken)
+	watching = make(map[string]bool)
+	cacheMu  sync.RWMutex
 )
 
-func initGRPCClient() {
+// client 返回 TokenService 客户端，连接断开后由 gRPC 自动重连；
+// 配置错误（如证书路径无效）时返回错误，下次调用重新尝试
+func client() (pb.TokenServiceClient, error) {
 	mu.Lock()
 	defer mu.Unlock()
 
 	if grpcClient != nil {
-		return
+		return grpcClient, nil
 	}
 
-	var err error
-	conn, err = grpc.NewClient(
-		"127.0.0.1:51001",
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	addr, opts, err := dialTarget()
+	if err != nil {
+		return nil, err
+	}
+	conn, err = grpc.NewClient(addr, opts...)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("连接 TokenService 失败: %v", err)
 	}
 	grpcClient = pb.NewTokenServiceClient(conn)
+	return grpcClient, nil
 }
 
+// GetAccessToken 获取默认公众号的 access_token
 func GetAccessToken() (string, error) {
-	if grpcClient == nil {
-		initGRPCClient()
+	return GetAccessTokenWithAppID(context.Background(), "")
+}
+
+// GetAccessTokenWithAppID 获取指定公众号的 access_token，appid 为空时由 TokenService 使用默认公众号。
+// 优先使用 WatchAccessToken 推送的本地缓存，缓存缺失或过期时回退到单次 gRPC 调用。
+func GetAccessTokenWithAppID(ctx context.Context, appid string) (string, error) {
+	startWatch(appid)
+
+	if token, ok := cachedAccessToken(appid); ok {
+		return token, nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+
+	cli, err := client()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
-	resp, err := grpcClient.GetAccessToken(ctx, &pb.TokenRequest{})
-	if err != nil {
-		// 失败时重建连接
-		mu.Lock()
-		grpcClient = nil
-		if conn != nil {
-			_ = conn.Close()
-			conn = nil
-		}
-		mu.Unlock()
+	resp, err := cli.GetAccessToken(ctx, &pb.TokenRequest{Appid: appid})
+	if err != nil {
+		return "", err
+	}
+	storeToken(appid, resp)
+	return resp.AccessToken, nil
+}
+
+// RefreshAccessTokenWithAppID 通知 TokenService 强制刷新 access_token，staleToken 为微信判定失效的 token
+func RefreshAccessTokenWithAppID(ctx context.Context, appid, staleToken string) (string, error) {
+	cli, err := client()
+	if err != nil {
+		return "", err
+	}
+	// 强制刷新需要等待 TokenService 请求微信，超时时间适当放宽
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := cli.RefreshAccessToken(ctx, &pb.RefreshRequest{Appid: appid, StaleToken: staleToken})
+	if err != nil {
 		return "", err
 	}
+	storeToken(appid, resp)
 	return resp.AccessToken, nil
 }
+
+// cachedAccessToken 读取未过期的本地缓存
+func cachedAccessToken(appid string) (string, bool) {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	t, ok := cache[appid]
+	if !ok || t.token == "" || !time.Now().Before(t.expireAt) {
+		return "", false
+	}
+	return t.token, true
+}
+
+// storeToken 更新本地缓存
+func storeToken(appid string, resp *pb.TokenReply) {
+	if resp.AccessToken == "" {
+		return
+	}
+	cacheMu.Lock()
+	cache[appid] = cachedToken{token: resp.AccessToken, expireAt: time.Unix(resp.ExpireAt, 0)}
+	cacheMu.Unlock()
+}
+
+// startWatch 为 appid 启动一个 WatchAccessToken 订阅协程（每个 appid 只启动一次）
+func startWatch(appid string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if watching[appid] {
+		return
+	}
+	watching[appid] = true
+	go watchLoop(appid)
+}
+
+// watchLoop 持续订阅 token 推送，断开后指数退避重连；服务端不支持订阅时退回单次调用模式
+func watchLoop(appid string) {
+	backoff := watchMinBackoff
+	for {
+		err := watchOnce(appid, func() { backoff = watchMinBackoff })
+		if status.Code(err) == codes.Unimplemented {
+			logger.Warnf("[token] TokenService 不支持 WatchAccessToken，AppID: %s 使用单次调用模式", appid)
+			return
+		}
+		logger.Warnf("[token] access_token 订阅断开，AppID: %s，%v 后重连: %v", appid, backoff, err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > watchMaxBackoff {
+			backoff = watchMaxBackoff
+		}
+	}
+}
+
+// watchOnce 建立一次订阅并持续接收推送，直到流断开；收到推送时调用 onRecv
+func watchOnce(appid string, onRecv func()) error {
+	cli, err := client()
+	if err != nil {
+		return err
+	}
+	stream, err := cli.WatchAccessToken(context.Background(), &pb.TokenRequest{Appid: appid})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return status.Error(codes.Unavailable, "stream closed by server")
+		}
+		if err != nil {
+			return err
+		}
+		storeToken(appid, resp)
+		onRecv()
+	}
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vxmsgpush/config"
+	pb "vxmsgpush/core/grpc/token"
+
+	"google.golang.org/grpc"
+)
+
+// fakeTokenService 第一次订阅推送 token-1 后断开，之后的订阅推送 token-2 并保持连接
+type fakeTokenService struct {
+	pb.UnimplementedTokenServiceServer
+	watches atomic.Int32
+	gets    atomic.Int32
+}
+
+func (s *fakeTokenService) GetAccessToken(ctx context.Context, req *pb.TokenRequest) (*pb.TokenReply, error) {
+	s.gets.Add(1)
+	return &pb.TokenReply{AccessToken: "token-0", ExpireAt: time.Now().Add(time.Hour).Unix(), Appid: req.Appid}, nil
+}
+
+func (s *fakeTokenService) WatchAccessToken(req *pb.TokenRequest, stream grpc.ServerStreamingServer[pb.TokenReply]) error {
+	expireAt := time.Now().Add(time.Hour).Unix()
+	if s.watches.Add(1) == 1 {
+		return stream.Send(&pb.TokenReply{AccessToken: "token-1", ExpireAt: expireAt, Appid: req.Appid})
+	}
+	if err := stream.Send(&pb.TokenReply{AccessToken: "token-2", ExpireAt: expireAt, Appid: req.Appid}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+// useTokenService 将 client() 指向 addr，测试结束后恢复配置并丢弃已建立的连接
+func useTokenService(t *testing.T, conf config.TokenServiceConfig) {
+	old := config.Conf.Token
+	config.Conf.Token = conf
+	resetClient := func() {
+		mu.Lock()
+		if conn != nil {
+			conn.Close()
+		}
+		grpcClient, conn = nil, nil
+		mu.Unlock()
+	}
+	resetClient()
+	t.Cleanup(func() {
+		resetClient()
+		config.Conf.Token = old
+	})
+}
+
+func TestWatchReconnect(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	svc := &fakeTokenService{}
+	gs := grpc.NewServer()
+	pb.RegisterTokenServiceServer(gs, svc)
+	go gs.Serve(lis)
+	defer gs.Stop()
+	useTokenService(t, config.TokenServiceConfig{Addr: lis.Addr().String()})
+
+	const appid = "wx-watch"
+	if _, err := GetAccessTokenWithAppID(context.Background(), appid); err != nil {
+		t.Fatalf("获取 token 失败: %v", err)
+	}
+
+	// 第一次订阅断开后应退避重连，并收到重连后推送的 token
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if token, _ := cachedAccessToken(appid); token == "token-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("订阅断开后未重连，订阅次数 %d", svc.watches.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := svc.watches.Load(); n != 2 {
+		t.Errorf("期望订阅 2 次，实际 %d 次", n)
+	}
+
+	// 缓存有效时不再发起单次调用
+	gets := svc.gets.Load()
+	token, err := GetAccessTokenWithAppID(context.Background(), appid)
+	if err != nil || token != "token-2" {
+		t.Fatalf("应返回推送的 token: %s %v", token, err)
+	}
+	if svc.gets.Load() != gets {
+		t.Errorf("缓存有效时不应调用 GetAccessToken")
+	}
+}
+
+func TestDialErrorReturned(t *testing.T) {
+	useTokenService(t, config.TokenServiceConfig{CAFile: "/nonexistent/ca.pem"})
+
+	if _, err := RefreshAccessTokenWithAppID(context.Background(), "wx-bad", ""); err == nil {
+		t.Fatalf("CA 文件不存在时应返回错误")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if grpcClient != nil {
+		t.Errorf("连接失败时不应缓存客户端")
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"vxmsgpush/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const defaultTokenServiceAddr = "127.0.0.1:51001"
+
+// clientKeyCredentials 在每次调用时携带客户端 id/key，与 TokenService 的认证拦截器对应
+type clientKeyCredentials struct {
+	clientID  string
+	clientKey string
+	secure    bool
+}
+
+func (c clientKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"x-client-id":  c.clientID,
+		"x-client-key": c.clientKey,
+	}, nil
+}
+
+func (c clientKeyCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+// dialTarget 返回 TokenService 地址及连接选项（TLS/mTLS、客户端密钥）
+func dialTarget() (string, []grpc.DialOption, error) {
+	conf := config.Conf.Token
+
+	addr := conf.Addr
+	if addr == "" {
+		addr = defaultTokenServiceAddr
+	}
+
+	useTLS := conf.TLS || conf.CAFile != "" || conf.CertFile != ""
+	var opts []grpc.DialOption
+	if useTLS {
+		tlsConf := &tls.Config{
+			ServerName: conf.ServerName,
+			MinVersion: tls.VersionTLS12,
+		}
+		if conf.CAFile != "" {
+			caPEM, err := os.ReadFile(conf.CAFile)
+			if err != nil {
+				return "", nil, fmt.Errorf("读取 TokenService CA 失败: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return "", nil, fmt.Errorf("CA 文件 %s 中没有有效证书", conf.CAFile)
+			}
+			tlsConf.RootCAs = pool
+		}
+		if conf.CertFile != "" || conf.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+			if err != nil {
+				return "", nil, fmt.Errorf("加载客户端证书失败: %v", err)
+			}
+			tlsConf.Certificates = []tls.Certificate{cert}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConf)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if conf.ClientKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(clientKeyCredentials{
+			clientID:  conf.ClientID,
+			clientKey: conf.ClientKey,
+			secure:    useTLS,
+		}))
+	}
+	return addr, opts, nil
+}
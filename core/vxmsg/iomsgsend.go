@@ -1,68 +1,183 @@
 package vxmsg
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"vxmsgpush/logger"
-	"vxmsgpush/core/vxmsg/internal"
 )
 
-type TextMsg struct {
-	ToUser  string `json:"touser"`
-	MsgType string `json:"msgtype"`
-	Text    struct {
-		Content string `json:"content"`
-	} `json:"text"`
+// 客服消息类型
+const (
+	CustomMsgText            = "text"
+	CustomMsgImage           = "image"
+	CustomMsgVoice           = "voice"
+	CustomMsgVideo           = "video"
+	CustomMsgMusic           = "music"
+	CustomMsgNews            = "news"   // 图文消息（点击跳转到外链）
+	CustomMsgMpNews          = "mpnews" // 图文消息（点击跳转到图文消息页面）
+	CustomMsgMenu            = "msgmenu"
+	CustomMsgMiniProgramPage = "miniprogrampage"
+)
+
+type CustomText struct {
+	Content string `json:"content"`
 }
 
-// SendTextMessage 发送文本客服消息
-func SendTextMessage(toUser string, content string) error {
-	accessToken, err := internal.GetAccessToken()
-	if err != nil {
-		logger.Errorf("获取access_token失败: %v", err)
-		return fmt.Errorf("获取access_token失败: %v", err)
+// CustomMedia 图片、语音、mpnews 消息，media_id 为已上传的素材
+type CustomMedia struct {
+	MediaID string `json:"media_id"`
+}
+
+type CustomVideo struct {
+	MediaID      string `json:"media_id"`
+	ThumbMediaID string `json:"thumb_media_id"`
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type CustomMusic struct {
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+	MusicURL     string `json:"musicurl"`
+	HQMusicURL   string `json:"hqmusicurl"`
+	ThumbMediaID string `json:"thumb_media_id"`
+}
+
+type CustomNews struct {
+	Articles []Article `json:"articles"` // 微信限制为 1 条
+}
+
+// CustomMenu 菜单消息，用户点击菜单项后会收到带有菜单 id 的文本消息
+type CustomMenu struct {
+	HeadContent string           `json:"head_content,omitempty"`
+	List        []CustomMenuItem `json:"list"`
+	TailContent string           `json:"tail_content,omitempty"`
+}
+
+type CustomMenuItem struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// CustomMiniProgramPage 小程序卡片，小程序需与公众号关联
+type CustomMiniProgramPage struct {
+	Title        string `json:"title"`
+	AppID        string `json:"appid"`
+	PagePath     string `json:"pagepath"`
+	ThumbMediaID string `json:"thumb_media_id"`
+}
+
+// CustomMsg 客服消息，按 MsgType 填写对应的内容字段；只能在用户最近 48 小时内与公众号互动过时发送
+type CustomMsg struct {
+	ToUser          string                 `json:"touser"`
+	MsgType         string                 `json:"msgtype"`
+	Text            *CustomText            `json:"text,omitempty"`
+	Image           *CustomMedia           `json:"image,omitempty"`
+	Voice           *CustomMedia           `json:"voice,omitempty"`
+	Video           *CustomVideo           `json:"video,omitempty"`
+	Music           *CustomMusic           `json:"music,omitempty"`
+	News            *CustomNews            `json:"news,omitempty"`
+	MpNews          *CustomMedia           `json:"mpnews,omitempty"`
+	MsgMenu         *CustomMenu            `json:"msgmenu,omitempty"`
+	MiniProgramPage *CustomMiniProgramPage `json:"miniprogrampage,omitempty"`
+}
+
+// TextMsg 旧版文本客服消息类型，保留以兼容已有调用方；Text 改为指针，需赋值 &CustomText{...}
+//
+// Deprecated: 使用 CustomMsg。
+type TextMsg = CustomMsg
+
+// Validate 校验 MsgType 对应的内容字段已填写（不校验 touser）
+func (m *CustomMsg) Validate() error {
+	var ok bool
+	switch m.MsgType {
+	case CustomMsgText:
+		ok = m.Text != nil && m.Text.Content != ""
+	case CustomMsgImage:
+		ok = m.Image != nil && m.Image.MediaID != ""
+	case CustomMsgVoice:
+		ok = m.Voice != nil && m.Voice.MediaID != ""
+	case CustomMsgVideo:
+		ok = m.Video != nil && m.Video.MediaID != "" && m.Video.ThumbMediaID != ""
+	case CustomMsgMusic:
+		ok = m.Music != nil && m.Music.MusicURL != "" && m.Music.ThumbMediaID != ""
+	case CustomMsgNews:
+		ok = m.News != nil && len(m.News.Articles) == 1
+	case CustomMsgMpNews:
+		ok = m.MpNews != nil && m.MpNews.MediaID != ""
+	case CustomMsgMenu:
+		ok = m.MsgMenu != nil && len(m.MsgMenu.List) > 0
+	case CustomMsgMiniProgramPage:
+		ok = m.MiniProgramPage != nil && m.MiniProgramPage.AppID != "" && m.MiniProgramPage.PagePath != "" && m.MiniProgramPage.ThumbMediaID != ""
+	default:
+		return fmt.Errorf("不支持的客服消息类型: %s", m.MsgType)
+	}
+	if !ok {
+		return fmt.Errorf("%s 消息内容不完整", m.MsgType)
 	}
-	logger.Infof("获取access_token成功: %s", accessToken)
+	return nil
+}
 
-	url := fmt.Sprintf("http://192.170.144.52:9010/weixin_api/cgi-bin/message/custom/send?access_token=%s", accessToken)
-	logger.Infof("发送客服消息，URL: %s", url)
+// SendTextMessage 使用默认公众号发送文本客服消息
+func SendTextMessage(toUser string, content string) error {
+	return DefaultClient("").SendText(context.Background(), toUser, content)
+}
 
-	msg := TextMsg{
+// SendCustomMessageWithAppID 使用指定公众号发送客服消息，appid 为空时使用默认公众号
+func SendCustomMessageWithAppID(appid string, msg CustomMsg) error {
+	return DefaultClient(appid).SendCustom(context.Background(), msg)
+}
+
+// SendTypingWithAppID 使用指定公众号下发“正在输入”状态
+func SendTypingWithAppID(appid, toUser string, typing bool) error {
+	return DefaultClient(appid).Typing(context.Background(), toUser, typing)
+}
+
+// SendText 发送文本客服消息
+func (c *Client) SendText(ctx context.Context, toUser string, content string) error {
+	return c.SendCustom(ctx, CustomMsg{
 		ToUser:  toUser,
-		MsgType: "text",
+		MsgType: CustomMsgText,
+		Text:    &CustomText{Content: content},
+	})
+}
+
+// SendCustom 发送客服消息
+func (c *Client) SendCustom(ctx context.Context, msg CustomMsg) error {
+	if msg.ToUser == "" {
+		return fmt.Errorf("touser 不能为空")
+	}
+	if err := msg.Validate(); err != nil {
+		return err
 	}
-	msg.Text.Content = content
 
 	data, err := json.Marshal(msg)
 	if err != nil {
-		logger.Errorf("消息内容序列化失败: %v", err)
+		c.log.Errorf("消息内容序列化失败: %v", err)
 		return fmt.Errorf("消息内容序列化失败: %v", err)
 	}
-	logger.Debugf("消息内容JSON: %s", string(data))
+	c.log.Debugf("消息内容JSON: %s", string(data))
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	err = c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/message/custom/send", accessToken, nil, data, nil)
+	})
 	if err != nil {
-		logger.Errorf("发送消息失败: %v", err)
-		return fmt.Errorf("发送消息失败: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		logger.Errorf("解析微信响应失败: %v", err)
-		return fmt.Errorf("解析微信响应失败: %v", err)
-	}
-	logger.Debugf("微信响应: %+v", result)
+	c.log.Infof("发送客服消息成功，AppID: %s，用户: %s，类型: %s", c.appID, msg.ToUser, msg.MsgType)
+	return nil
+}
 
-	if errcode, ok := result["errcode"].(float64); ok && errcode != 0 {
-		errmsg := result["errmsg"]
-		logger.Errorf("微信返回错误: %v", errmsg)
-		return fmt.Errorf("微信返回错误: %v", errmsg)
+// Typing 下发或取消“正在输入”状态，用户 48 小时内与公众号互动过才能下发
+func (c *Client) Typing(ctx context.Context, toUser string, typing bool) error {
+	command := "Typing"
+	if !typing {
+		command = "CancelTyping"
 	}
+	data, _ := json.Marshal(map[string]string{"touser": toUser, "command": command})
 
-	logger.Infof("发送消息成功，用户: %s，内容: %s", toUser, content)
-	return nil
+	return c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/message/custom/typing", accessToken, nil, data, nil)
+	})
 }
-
@@ -0,0 +1,142 @@
+package vxmsg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 回调消息加解密方式
+const (
+	CallbackModePlain      = "plain"      // 明文模式
+	CallbackModeCompatible = "compatible" // 兼容模式：同时接受明文与密文
+	CallbackModeSafe       = "safe"       // 安全模式：只接受密文
+)
+
+// 微信消息加解密 PKCS#7 填充使用 32 字节块
+const cryptBlockSize = 32
+
+// MsgCrypter 微信回调消息加解密（AES-256-CBC，密钥由 EncodingAESKey 解码得到，IV 取密钥前 16 字节）
+type MsgCrypter struct {
+	token string
+	appID string
+	key   []byte
+}
+
+// NewMsgCrypter 创建加解密器，encodingAESKey 为公众号后台配置的 43 位字符串
+func NewMsgCrypter(token, encodingAESKey, appID string) (*MsgCrypter, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, fmt.Errorf("EncodingAESKey 长度应为 43 位")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("EncodingAESKey 解码失败: %v", err)
+	}
+	return &MsgCrypter{token: token, appID: appID, key: key}, nil
+}
+
+// Signature 计算 msg_signature：token、timestamp、nonce、密文字典序排序拼接后取 SHA1
+func (m *MsgCrypter) Signature(timestamp, nonce, encrypt string) string {
+	list := []string{m.token, timestamp, nonce, encrypt}
+	sort.Strings(list)
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(list, ""))))
+}
+
+// Decrypt 解密 Encrypt 字段，明文格式为 16 字节随机串 + 4 字节消息长度 + 消息 + appid
+func (m *MsgCrypter) Decrypt(encrypt string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, fmt.Errorf("密文解码失败: %v", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("密文长度错误")
+	}
+
+	block, err := aes.NewCipher(m.key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, m.key[:aes.BlockSize]).CryptBlocks(plain, data)
+
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > cryptBlockSize || pad > len(plain) {
+		return nil, fmt.Errorf("填充错误")
+	}
+	plain = plain[:len(plain)-pad]
+	if len(plain) < 20 {
+		return nil, fmt.Errorf("明文长度错误")
+	}
+
+	msgLen := int(binary.BigEndian.Uint32(plain[16:20]))
+	if msgLen > len(plain)-20 {
+		return nil, fmt.Errorf("消息长度错误")
+	}
+	msg := plain[20 : 20+msgLen]
+	if appID := string(plain[20+msgLen:]); m.appID != "" && appID != m.appID {
+		return nil, fmt.Errorf("appid 不匹配: %s", appID)
+	}
+	return msg, nil
+}
+
+// Encrypt 加密消息，返回 base64 编码的密文
+func (m *MsgCrypter) Encrypt(msg []byte) (string, error) {
+	var buf bytes.Buffer
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	buf.Write(random)
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg)))
+	buf.Write(msg)
+	buf.WriteString(m.appID)
+
+	pad := cryptBlockSize - buf.Len()%cryptBlockSize
+	buf.Write(bytes.Repeat([]byte{byte(pad)}, pad))
+
+	block, err := aes.NewCipher(m.key)
+	if err != nil {
+		return "", err
+	}
+	data := buf.Bytes()
+	cipher.NewCBCEncrypter(block, m.key[:aes.BlockSize]).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// EncryptedMessage 安全模式下微信推送的消息外层
+type EncryptedMessage struct {
+	XMLName    xml.Name `xml:"xml"`
+	ToUserName string   `xml:"ToUserName"`
+	Encrypt    string   `xml:"Encrypt"`
+}
+
+// encryptedReply 安全模式下的被动回复外层
+type encryptedReply struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      cdata    `xml:"Encrypt"`
+	MsgSignature cdata    `xml:"MsgSignature"`
+	TimeStamp    string   `xml:"TimeStamp"`
+	Nonce        cdata    `xml:"Nonce"`
+}
+
+// EncryptReply 加密被动回复并附带签名
+func (m *MsgCrypter) EncryptReply(reply []byte, timestamp, nonce string) ([]byte, error) {
+	encrypt, err := m.Encrypt(reply)
+	if err != nil {
+		return nil, fmt.Errorf("加密回复失败: %v", err)
+	}
+	return xml.Marshal(encryptedReply{
+		Encrypt:      cdata{encrypt},
+		MsgSignature: cdata{m.Signature(timestamp, nonce, encrypt)},
+		TimeStamp:    timestamp,
+		Nonce:        cdata{nonce},
+	})
+}
@@ -0,0 +1,103 @@
+package vxmsg
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Reply 被动回复消息，由 NewTextReply / NewImageReply / NewNewsReply 构造
+type Reply interface {
+	// Bytes 序列化为回复给微信服务器的 XML
+	Bytes() ([]byte, error)
+}
+
+// cdata 以 <![CDATA[...]]> 输出的文本
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+// replyHeader 被动回复的公共字段，收发方与收到的消息相反
+type replyHeader struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   cdata    `xml:"ToUserName"`
+	FromUserName cdata    `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      cdata    `xml:"MsgType"`
+}
+
+func newReplyHeader(msg *CallbackMessage, msgType string) replyHeader {
+	return replyHeader{
+		ToUserName:   cdata{msg.FromUserName},
+		FromUserName: cdata{msg.ToUserName},
+		CreateTime:   time.Now().Unix(),
+		MsgType:      cdata{msgType},
+	}
+}
+
+// TextReply 文本回复
+type TextReply struct {
+	replyHeader
+	Content cdata `xml:"Content"`
+}
+
+// NewTextReply 构造对 msg 的文本回复
+func NewTextReply(msg *CallbackMessage, content string) *TextReply {
+	return &TextReply{replyHeader: newReplyHeader(msg, MsgTypeText), Content: cdata{content}}
+}
+
+func (r *TextReply) Bytes() ([]byte, error) { return xml.Marshal(r) }
+
+// ImageReply 图片回复，mediaID 为已上传素材的 media_id
+type ImageReply struct {
+	replyHeader
+	Image struct {
+		MediaID cdata `xml:"MediaId"`
+	} `xml:"Image"`
+}
+
+// NewImageReply 构造对 msg 的图片回复
+func NewImageReply(msg *CallbackMessage, mediaID string) *ImageReply {
+	r := &ImageReply{replyHeader: newReplyHeader(msg, MsgTypeImage)}
+	r.Image.MediaID = cdata{mediaID}
+	return r
+}
+
+func (r *ImageReply) Bytes() ([]byte, error) { return xml.Marshal(r) }
+
+// Article 图文回复中的单条图文
+type Article struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PicURL      string `json:"picurl"`
+	URL         string `json:"url"`
+}
+
+type articleItem struct {
+	Title       cdata `xml:"Title"`
+	Description cdata `xml:"Description"`
+	PicURL      cdata `xml:"PicUrl"`
+	URL         cdata `xml:"Url"`
+}
+
+// NewsReply 图文回复（微信限制最多 1 条图文）
+type NewsReply struct {
+	replyHeader
+	ArticleCount int           `xml:"ArticleCount"`
+	Articles     []articleItem `xml:"Articles>item"`
+}
+
+// NewNewsReply 构造对 msg 的图文回复
+func NewNewsReply(msg *CallbackMessage, articles ...Article) *NewsReply {
+	r := &NewsReply{replyHeader: newReplyHeader(msg, "news"), ArticleCount: len(articles)}
+	for _, a := range articles {
+		r.Articles = append(r.Articles, articleItem{
+			Title:       cdata{a.Title},
+			Description: cdata{a.Description},
+			PicURL:      cdata{a.PicURL},
+			URL:         cdata{a.URL},
+		})
+	}
+	return r
+}
+
+func (r *NewsReply) Bytes() ([]byte, error) { return xml.Marshal(r) }
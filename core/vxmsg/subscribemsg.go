@@ -0,0 +1,42 @@
+package vxmsg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SubscribeMsg 公众号订阅通知（一次性 / 长期订阅），用户订阅后才能下发
+type SubscribeMsg struct {
+	ToUser      string                 `json:"touser"`
+	TemplateID  string                 `json:"template_id"`
+	Page        string                 `json:"page,omitempty"` // 跳转网页，与 miniprogram 同时填写时优先跳转小程序
+	MiniProgram *MiniProgram           `json:"miniprogram,omitempty"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+// SendSubscribeMsgWithAppID 使用指定公众号发送订阅通知，appid 为空时使用默认公众号
+func SendSubscribeMsgWithAppID(appid string, msg SubscribeMsg) (*SendResult, error) {
+	return DefaultClient(appid).SendSubscribe(context.Background(), msg)
+}
+
+// SendSubscribe 发送订阅通知（message/subscribe/bizsend）
+func (c *Client) SendSubscribe(ctx context.Context, msg SubscribeMsg) (*SendResult, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.log.Errorf("订阅通知序列化失败: %v", err)
+		return nil, fmt.Errorf("订阅通知序列化失败: %v", err)
+	}
+	c.log.Debugf("订阅通知JSON: %s", string(data))
+
+	var result SendResult
+	err = c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/message/subscribe/bizsend", accessToken, nil, data, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Infof("发送订阅通知成功，AppID: %s，用户: %s，模板ID: %s", c.appID, msg.ToUser, msg.TemplateID)
+	return &result, nil
+}
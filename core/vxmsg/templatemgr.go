@@ -0,0 +1,124 @@
+package vxmsg
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// PrivateTemplate 公众号已添加的模板
+type PrivateTemplate struct {
+	TemplateID      string `json:"template_id"`
+	Title           string `json:"title"`
+	PrimaryIndustry string `json:"primary_industry"`
+	DeputyIndustry  string `json:"deputy_industry"`
+	Content         string `json:"content"` // 如 "申请人:{{thing1.DATA}}\n办理进度:{{phrase3.DATA}}"
+	Example         string `json:"example"`
+}
+
+// TemplateKeyword 模板内容中的字段
+type TemplateKeyword struct {
+	Key   string `json:"key"`   // 字段名，如 thing1
+	Type  string `json:"type"`  // 字段类型，即去掉序号的字段名，如 thing
+	Label string `json:"label"` // 字段说明，如 申请人
+}
+
+// IndustryClass 行业分类
+type IndustryClass struct {
+	FirstClass  string `json:"first_class"`
+	SecondClass string `json:"second_class"`
+}
+
+// Industry 公众号设置的所属行业
+type Industry struct {
+	PrimaryIndustry   IndustryClass `json:"primary_industry"`
+	SecondaryIndustry IndustryClass `json:"secondary_industry"`
+}
+
+var keywordPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_]+)(\d*)\.DATA\s*\}\}`)
+
+// ParseTemplateKeywords 从模板内容中解析字段，字段说明取同一行中占位符前的文字
+func ParseTemplateKeywords(content string) []TemplateKeyword {
+	var keywords []TemplateKeyword
+	for _, line := range strings.Split(content, "\n") {
+		prev := 0
+		for _, m := range keywordPattern.FindAllStringSubmatchIndex(line, -1) {
+			label := strings.TrimSpace(line[prev:m[0]])
+			label = strings.TrimSpace(strings.TrimRight(label, ":："))
+			keywords = append(keywords, TemplateKeyword{
+				Key:   line[m[2]:m[5]],
+				Type:  line[m[2]:m[3]],
+				Label: label,
+			})
+			prev = m[1]
+		}
+	}
+	return keywords
+}
+
+// GetAllPrivateTemplates 获取公众号已添加的全部模板
+func (c *Client) GetAllPrivateTemplates(ctx context.Context) ([]PrivateTemplate, error) {
+	var result struct {
+		TemplateList []PrivateTemplate `json:"template_list"`
+	}
+	err := c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/template/get_all_private_template", accessToken, nil, nil, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.TemplateList, nil
+}
+
+// AddTemplate 从模板库添加模板，返回模板 ID；keywordNames 为选用的关键词名称（类目模板必填）
+func (c *Client) AddTemplate(ctx context.Context, templateIDShort string, keywordNames []string) (string, error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"template_id_short": templateIDShort,
+		"keyword_name_list": keywordNames,
+	})
+	var result struct {
+		TemplateID string `json:"template_id"`
+	}
+	err := c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/template/api_add_template", accessToken, nil, data, &result)
+	})
+	if err != nil {
+		return "", err
+	}
+	c.log.Infof("添加模板成功，AppID: %s，模板ID: %s", c.appID, result.TemplateID)
+	return result.TemplateID, nil
+}
+
+// DeletePrivateTemplate 删除公众号已添加的模板
+func (c *Client) DeletePrivateTemplate(ctx context.Context, templateID string) error {
+	data, _ := json.Marshal(map[string]string{"template_id": templateID})
+	err := c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/template/del_private_template", accessToken, nil, data, nil)
+	})
+	if err != nil {
+		return err
+	}
+	c.log.Infof("删除模板成功，AppID: %s，模板ID: %s", c.appID, templateID)
+	return nil
+}
+
+// GetIndustry 获取公众号设置的所属行业
+func (c *Client) GetIndustry(ctx context.Context) (*Industry, error) {
+	var result Industry
+	err := c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/template/get_industry", accessToken, nil, nil, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetIndustry 设置公众号所属行业（每月可修改一次）
+func (c *Client) SetIndustry(ctx context.Context, industryID1, industryID2 string) error {
+	data, _ := json.Marshal(map[string]string{"industry_id1": industryID1, "industry_id2": industryID2})
+	return c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/cgi-bin/template/api_set_industry", accessToken, nil, data, nil)
+	})
+}
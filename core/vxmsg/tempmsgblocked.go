@@ -1,39 +1,25 @@
 package vxmsg
 
 import (
-	"fmt"
-	"io/ioutil"
-	"net/http"
-
-	"vxmsgpush/logger"
-	"vxmsgpush/core/vxmsg/internal"
+	"context"
 )
 
 // QueryBlockedTemplateMsg 根据消息 ID 查询是否被拦截
 func QueryBlockedTemplateMsg(msgID string) error {
-	accessToken, err := internal.GetAccessToken()
-	if err != nil {
-		logger.Errorf("获取 access_token 失败: %v", err)
-		return fmt.Errorf("access_token 获取失败: %v", err)
-	}
-	logger.Infof("access_token 获取成功")
-
-	url := fmt.Sprintf("http://192.170.144.52:9010/weixin_api/wxa/sec/queryblocktmplmsg?access_token=%s", accessToken)
-	reqURL := fmt.Sprintf("%s&msgid=%s", url, msgID)
+	return DefaultClient("").QueryBlockedTemplate(context.Background(), msgID)
+}
 
-	resp, err := http.Get(reqURL)
+// QueryBlockedTemplate 根据消息 ID 查询模板消息是否被拦截
+func (c *Client) QueryBlockedTemplate(ctx context.Context, msgID string) error {
+	var result map[string]interface{}
+	err := c.withToken(ctx, func(accessToken string) error {
+		return c.do(ctx, "/wxa/sec/queryblocktmplmsg", accessToken, map[string]string{"msgid": msgID}, nil, &result)
+	})
 	if err != nil {
-		logger.Errorf("请求微信接口失败: %v", err)
+		c.log.Errorf("查询模板消息拦截状态失败: %v", err)
 		return err
 	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	logger.Infof("查询结果: %s", string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("查询失败，状态码: %d，响应: %s", resp.StatusCode, string(body))
-	}
 
+	c.log.Infof("查询结果: %v", result)
 	return nil
 }
@@ -0,0 +1,191 @@
+package vxmsg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldChange 规范化时对模板字段值的修改
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Reason string `json:"reason"`
+}
+
+// 规范化时额外识别的日期时间格式，转换为微信接受的格式
+var extraTimeLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{time.RFC3339, true},
+	{"2006-01-02T15:04:05", true},
+	{"2006-01-02 15:04:05.000", true},
+	{"20060102150405", true},
+	{"2006.01.02 15:04:05", true},
+	{"2006.01.02 15:04", true},
+	{"2006/1/2 15:04:05", true},
+	{"2006/1/2 15:04", true},
+	{"2006-1-2 15:04", true},
+	{"20060102", false},
+	{"2006.01.02", false},
+	{"2006/1/2", false},
+	{"2006-1-2", false},
+}
+
+// NormalizeTemplateData 按模板字段类型规范化 data：超长截断（thing、name 以省略号结尾）、
+// 去除字段类型不允许的字符、将常见日期时间格式转换为微信接受的格式。
+// 返回新的 data（不修改入参）以及被修改的字段。
+func NormalizeTemplateData(keywords []TemplateKeyword, data map[string]interface{}) (map[string]interface{}, []FieldChange) {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+
+	var changes []FieldChange
+	for _, kw := range keywords {
+		raw, ok := data[kw.Key]
+		if !ok {
+			continue
+		}
+		value, ok := TemplateValue(raw)
+		if !ok {
+			continue
+		}
+		after, reasons := normalizeKeywordValue(kw.Type, value)
+		if after == value {
+			continue
+		}
+
+		field := make(map[string]interface{})
+		for k, v := range raw.(map[string]interface{}) {
+			field[k] = v
+		}
+		field["value"] = after
+		out[kw.Key] = field
+		changes = append(changes, FieldChange{Field: kw.Key, Before: value, After: after, Reason: strings.Join(reasons, "；")})
+	}
+	return out, changes
+}
+
+// normalizeKeywordValue 按字段类型规范化单个值，返回规范化后的值与修改原因
+func normalizeKeywordValue(typ, value string) (string, []string) {
+	var reasons []string
+	apply := func(after, reason string) {
+		if after != value {
+			value = after
+			reasons = append(reasons, reason)
+		}
+	}
+
+	switch typ {
+	case "thing":
+		apply(collapseSpace(value), "去除换行等控制字符")
+		apply(truncateWithEllipsis(value, 20, "…"), "超过 20 个字符，已截断")
+	case "name":
+		apply(collapseSpace(value), "去除换行等控制字符")
+		limit := 10
+		if asciiNamePattern.MatchString(value) {
+			limit = 20
+		}
+		apply(truncateWithEllipsis(value, limit, "…"), fmt.Sprintf("超过 %d 个字符，已截断", limit))
+	case "character_string":
+		apply(keepRunes(value, func(r rune) bool { return r >= 0x21 && r <= 0x7e }), "去除数字、字母、符号以外的字符")
+		apply(truncateWithEllipsis(value, 32, "..."), "超过 32 位，已截断")
+	case "letter":
+		apply(keepRunes(value, func(r rune) bool { return r < utf8.RuneSelf && unicode.IsLetter(r) }), "去除字母以外的字符")
+		apply(truncateRunes(value, 32), "超过 32 位，已截断")
+	case "number":
+		apply(keepRunes(value, func(r rune) bool { return unicode.IsDigit(r) || r == '.' || r == '-' }), "去除数字以外的字符")
+	case "amount":
+		apply(keepRunes(value, func(r rune) bool { return r != ',' && r != '，' && !unicode.IsSpace(r) }), "去除千分位与空格")
+	case "phone_number":
+		apply(keepRunes(value, func(r rune) bool { return unicode.IsDigit(r) || r == '+' || r == '-' }), "去除数字、+、- 以外的字符")
+	case "car_number":
+		apply(keepRunes(value, func(r rune) bool { return !unicode.IsSpace(r) }), "去除空格")
+		apply(truncateRunes(value, 8), "超过 8 个字符，已截断")
+	case "symbol":
+		apply(truncateRunes(value, 5), "超过 5 个字符，已截断")
+	case "phrase":
+		apply(keepRunes(value, func(r rune) bool { return unicode.Is(unicode.Han, r) }), "去除汉字以外的字符")
+		apply(truncateRunes(value, 5), "超过 5 个字符，已截断")
+	case "time", "date":
+		apply(reformatTimeRange(value, typ == "time"), "时间格式已转换")
+	}
+	return value, reasons
+}
+
+// reformatTimeRange 将无法通过校验的日期时间（或用 ~ 连接的时间段）转换为 2006年1月2日 15:04 格式，无法识别时原样返回
+func reformatTimeRange(value string, timeRequired bool) string {
+	if matchTimeRange(value, timeRequired) {
+		return value
+	}
+	parts := strings.Split(value, "~")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if matchTime(part, timeRequired) {
+			parts[i] = part
+			continue
+		}
+		formatted, ok := reformatTime(part, timeRequired)
+		if !ok {
+			return value
+		}
+		parts[i] = formatted
+	}
+	return strings.Join(parts, "~")
+}
+
+func reformatTime(value string, timeRequired bool) (string, bool) {
+	for _, l := range extraTimeLayouts {
+		if timeRequired && !l.hasTime {
+			// time 类型不补全时分
+			continue
+		}
+		t, err := time.Parse(l.layout, value)
+		if err != nil {
+			continue
+		}
+		if l.hasTime {
+			return t.Format("2006年1月2日 15:04"), true
+		}
+		return t.Format("2006年1月2日"), true
+	}
+	return "", false
+}
+
+// collapseSpace 将换行、制表等空白字符替换为单个空格，并去除首尾空白
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// keepRunes 只保留满足 keep 的字符
+func keepRunes(s string, keep func(rune) bool) string {
+	return strings.Map(func(r rune) rune {
+		if keep(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// truncateRunes 按字符截断到 n 个字符
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// truncateWithEllipsis 超过 n 个字符时截断并以 ellipsis 结尾，结果不超过 n 个字符
+func truncateWithEllipsis(s string, n int, ellipsis string) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-utf8.RuneCountInString(ellipsis)]) + ellipsis
+}
@@ -1,14 +1,11 @@
 package vxmsg
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
-
-	"vxmsgpush/logger"
-	"vxmsgpush/core/vxmsg/internal"
 )
 
 type MiniProgram struct {
@@ -34,54 +31,46 @@ func (e *WechatError) Error() string {
 	return fmt.Sprintf("微信返回错误: %d - %s", e.ErrCode, e.ErrMsg)
 }
 
-// SendTemplateMsg 发送模板消息
-func SendTemplateMsg(msg TemplateMsg) error {
-	accessToken, err := internal.GetAccessToken()
-	if err != nil {
-		logger.Errorf("获取access_token失败: %v", err)
-		return fmt.Errorf("获取access_token失败: %v", err)
-	}
-	logger.Infof("获取access_token成功: %s", accessToken)
+// SendResult 模板消息发送结果
+type SendResult struct {
+	MsgID int64 `json:"msgid"` // 微信返回的消息 ID，模板消息送达回调（TEMPLATESENDJOBFINISH）中的 MsgID 与之对应
+}
 
-	url := fmt.Sprintf("http://192.170.144.52:9010/weixin_api/cgi-bin/message/template/send?access_token=%s", accessToken)
-	logger.Infof("发送模板消息，URL: %s", url)
+// SendTemplateMsg 使用默认公众号发送模板消息
+func SendTemplateMsg(msg TemplateMsg) (*SendResult, error) {
+	return SendTemplateMsgWithAppID("", msg)
+}
+
+// SendTemplateMsgWithAppID 使用指定公众号发送模板消息，appid 为空时使用默认公众号
+func SendTemplateMsgWithAppID(appid string, msg TemplateMsg) (*SendResult, error) {
+	return DefaultClient(appid).SendTemplate(context.Background(), msg)
+}
 
+// SendTemplate 发送模板消息，成功时返回微信分配的 msgid
+func (c *Client) SendTemplate(ctx context.Context, msg TemplateMsg) (*SendResult, error) {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		logger.Errorf("模板消息序列化失败: %v", err)
-		return fmt.Errorf("模板消息序列化失败: %v", err)
+		c.log.Errorf("模板消息序列化失败: %v", err)
+		return nil, fmt.Errorf("模板消息序列化失败: %v", err)
 	}
-	logger.Debugf("模板消息JSON: %s", string(data))
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	reqBody := bytes.NewBuffer(data)
+	c.log.Debugf("模板消息JSON: %s", string(data))
 
-	resp, err := client.Post(url, "application/json", reqBody)
-	if err != nil {
-		logger.Warnf("第一次发送失败，准备重试: %v", err)
-		time.Sleep(500 * time.Millisecond)
-
-		reqBody = bytes.NewBuffer(data)
-		resp, err = client.Post(url, "application/json", reqBody)
-		if err != nil {
-			return fmt.Errorf("请求微信失败: %v", err)
+	var result SendResult
+	err = c.withToken(ctx, func(accessToken string) error {
+		err := c.do(ctx, "/cgi-bin/message/template/send", accessToken, nil, data, &result)
+		var we *WechatError
+		if err != nil && !errors.As(err, &we) && ctx.Err() == nil {
+			// 网络错误重试一次
+			c.log.Warnf("第一次发送失败，准备重试: %v", err)
+			time.Sleep(500 * time.Millisecond)
+			err = c.do(ctx, "/cgi-bin/message/template/send", accessToken, nil, data, &result)
 		}
-	}
-	defer resp.Body.Close()
-
-	// 响应结果结构体
-	var result WechatError
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		logger.Errorf("解析微信响应失败: %v", err)
-		return fmt.Errorf("解析微信响应失败: %v", err)
-	}
-	logger.Infof("微信响应: %+v", result)
-
-	if result.ErrCode != 0 {
-		logger.Errorf("微信返回错误: %d - %s", result.ErrCode, result.ErrMsg)
-		return &result // 返回结构化错误
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	logger.Infof("发送模板消息成功，用户: %s，模板ID: %s", msg.ToUser, msg.TemplateID)
-	return nil
+	c.log.Infof("发送模板消息成功，AppID: %s，用户: %s，模板ID: %s，msgid: %d", c.appID, msg.ToUser, msg.TemplateID, result.MsgID)
+	return &result, nil
 }
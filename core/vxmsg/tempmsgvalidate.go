@@ -0,0 +1,192 @@
+package vxmsg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError 模板字段校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// 旧版模板的首尾字段不受类型规则约束，可以不填
+var optionalKeywords = map[string]bool{"first": true, "remark": true}
+
+var (
+	numberPattern     = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	letterPattern     = regexp.MustCompile(`^[a-zA-Z]+$`)
+	symbolPattern     = regexp.MustCompile(`^[^\p{L}\p{N}\s]+$`)
+	charStringPattern = regexp.MustCompile(`^[\x21-\x7e]+$`)
+	phonePattern      = regexp.MustCompile(`^[0-9+\-]+$`)
+	amountPattern     = regexp.MustCompile(`^[¥￥$€£]?\d{1,10}(\.\d{1,2})?元?$`)
+	phrasePattern     = regexp.MustCompile(`^\p{Han}+$`)
+	asciiNamePattern  = regexp.MustCompile(`^[a-zA-Z .]+$`)
+)
+
+// 日期与时间字段接受的格式，时间段用 ~ 连接
+var (
+	dateLayouts = []string{"2006年1月2日", "2006-01-02", "2006/01/02"}
+	timeLayouts = []string{"15:04", "15:04:05"}
+)
+
+// ValidateTemplateData 按模板字段校验 data：声明的字段必须填写、不允许未声明的字段、值需符合字段类型的规则
+func ValidateTemplateData(keywords []TemplateKeyword, data map[string]interface{}) []FieldError {
+	var errs []FieldError
+	declared := make(map[string]bool, len(keywords))
+
+	for _, kw := range keywords {
+		declared[kw.Key] = true
+		raw, ok := data[kw.Key]
+		if !ok {
+			if !optionalKeywords[kw.Key] {
+				errs = append(errs, FieldError{Field: kw.Key, Message: fmt.Sprintf("缺少字段（%s）", kw.Label)})
+			}
+			continue
+		}
+		value, ok := TemplateValue(raw)
+		if !ok {
+			errs = append(errs, FieldError{Field: kw.Key, Message: `格式应为 {"value": "..."}`})
+			continue
+		}
+		if msg := checkKeywordValue(kw.Type, value); msg != "" {
+			errs = append(errs, FieldError{Field: kw.Key, Message: msg})
+		}
+	}
+
+	var unknown []string
+	for key := range data {
+		if !declared[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, FieldError{Field: key, Message: "模板中没有该字段"})
+	}
+	return errs
+}
+
+// TemplateValue 读取 {"value": "..."} 格式字段的值，数字与布尔值（如 {"value": 123}）按微信接收的文本格式转换
+func TemplateValue(raw interface{}) (string, bool) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	switch v := m["value"].(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return v.String(), true
+	case int, int64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// checkKeywordValue 按微信字段类型规则校验值，返回空字符串表示通过
+func checkKeywordValue(typ, value string) string {
+	if optionalKeywords[typ] || strings.HasPrefix(typ, "keyword") {
+		// 旧版模板字段没有类型约束
+		return ""
+	}
+	if value == "" {
+		return "不能为空"
+	}
+
+	n := utf8.RuneCountInString(value)
+	switch typ {
+	case "thing":
+		if n > 20 {
+			return fmt.Sprintf("thing 类型不能超过 20 个字符，当前 %d 个", n)
+		}
+	case "number":
+		if n > 32 || !numberPattern.MatchString(value) {
+			return "number 类型应为 32 位以内的数字，可带小数"
+		}
+	case "letter":
+		if n > 32 || !letterPattern.MatchString(value) {
+			return "letter 类型应为 32 位以内的字母"
+		}
+	case "symbol":
+		if n > 5 || !symbolPattern.MatchString(value) {
+			return "symbol 类型应为 5 位以内的符号"
+		}
+	case "character_string":
+		if n > 32 || !charStringPattern.MatchString(value) {
+			return "character_string 类型应为 32 位以内的数字、字母或符号"
+		}
+	case "phone_number":
+		if n > 17 || !phonePattern.MatchString(value) {
+			return "phone_number 类型应为 17 位以内的数字或符号"
+		}
+	case "amount":
+		if !amountPattern.MatchString(value) {
+			return "amount 类型应为币种符号加 10 位以内的数字，可带小数，结尾可带“元”"
+		}
+	case "car_number":
+		if n > 8 {
+			return "car_number 类型不能超过 8 个字符"
+		}
+	case "name":
+		if asciiNamePattern.MatchString(value) {
+			if n > 20 {
+				return "name 类型为字母时不能超过 20 个字符"
+			}
+		} else if n > 10 {
+			return "name 类型不能超过 10 个字符"
+		}
+	case "phrase":
+		if n > 5 || !phrasePattern.MatchString(value) {
+			return "phrase 类型应为 5 个以内的汉字"
+		}
+	case "time":
+		if !matchTimeRange(value, true) {
+			return "time 类型应为 24 小时制时间（可带年月日），如 15:01 或 2019年10月1日 15:01"
+		}
+	case "date":
+		if !matchTimeRange(value, false) {
+			return "date 类型应为年月日（可带 24 小时制时间），如 2019年10月1日 或 2019-10-01 15:01"
+		}
+	}
+	return ""
+}
+
+// matchTimeRange 校验时间或时间段（用 ~ 连接）；timeRequired 为 true 时必须包含时分
+func matchTimeRange(value string, timeRequired bool) bool {
+	for _, part := range strings.Split(value, "~") {
+		if !matchTime(strings.TrimSpace(part), timeRequired) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTime(value string, timeRequired bool) bool {
+	var layouts []string
+	if timeRequired {
+		layouts = append(layouts, timeLayouts...)
+	} else {
+		layouts = append(layouts, dateLayouts...)
+	}
+	for _, d := range dateLayouts {
+		for _, t := range timeLayouts {
+			layouts = append(layouts, d+" "+t)
+		}
+	}
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
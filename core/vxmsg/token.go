@@ -0,0 +1,20 @@
+package vxmsg
+
+import (
+	"context"
+
+	"vxmsgpush/core/vxmsg/internal"
+)
+
+// grpcTokenSource 从 TokenService 获取指定公众号的 access_token
+type grpcTokenSource struct {
+	appID string
+}
+
+func (s grpcTokenSource) Token(ctx context.Context) (string, error) {
+	return internal.GetAccessTokenWithAppID(ctx, s.appID)
+}
+
+func (s grpcTokenSource) Refresh(ctx context.Context, stale string) (string, error) {
+	return internal.RefreshAccessTokenWithAppID(ctx, s.appID, stale)
+}
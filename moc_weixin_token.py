@@ -24,5 +24,41 @@ def get_token():
         "errmsg": "ok"
     })
 
+@app.route("/weixin_api/cgi-bin/stable_token", methods=["POST"])
+def get_stable_token():
+    body = request.get_json(silent=True) or {}
+    appid = body.get("appid")
+    secret = body.get("secret")
+
+    if body.get("grant_type") != "client_credential" or not appid or not secret:
+        return jsonify({
+            "errcode": 40001,
+            "errmsg": "invalid request"
+        })
+
+    suffix = "-force" if body.get("force_refresh") else ""
+    return jsonify({
+        "access_token": f"mock-stable-token-{appid}{suffix}",
+        "expires_in": 7200
+    })
+
+@app.route("/weixin_api/cgi-bin/ticket/getticket", methods=["GET"])
+def get_ticket():
+    access_token = request.args.get("access_token")
+    ticket_type = request.args.get("type", "jsapi")
+
+    if not access_token:
+        return jsonify({
+            "errcode": 40001,
+            "errmsg": "invalid credential"
+        })
+
+    return jsonify({
+        "errcode": 0,
+        "errmsg": "ok",
+        "ticket": f"mock-{ticket_type}-ticket",
+        "expires_in": 7200
+    })
+
 if __name__ == "__main__":
     app.run(host="0.0.0.0", port=9011)
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vxmsgpush/api/handler"
+	"vxmsgpush/core/vxmsg"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAutoReplyRules(t *testing.T) {
+	useDefaultAppID(t, "wx-default")
+	fdb := useFakeDB(t)
+	var listed []string
+	fdb.handle("FROM push_autoreply_rule WHERE appid", func(args []driver.Value) ([]string, [][]driver.Value) {
+		listed = append(listed, args[0].(string))
+		now := time.Now()
+		columns := []string{"id", "appid", "rule_type", "match_type", "keyword", "reply_type", "reply_content", "priority", "enabled", "created_at", "updated_at"}
+		// 按优先级从高到低返回
+		return columns, [][]driver.Value{
+			{int64(1), "", "keyword", "exact", "进度", "text", "请登录查询办理进度", int64(30), true, now, now},
+			{int64(2), "", "keyword", "regex", `^\d{6}$`, "text", "验证码已收到", int64(20), true, now, now},
+			{int64(3), "", "keyword", "regex", `([`, "text", "无效规则", int64(15), true, now, now},
+			{int64(4), "", "keyword", "prefix", "办事", "news", `[{"title":"办事指南","url":"https://example.com"}]`, int64(10), true, now, now},
+			{int64(5), "", "subscribe", "", "", "text", "欢迎关注", int64(0), true, now, now},
+			{int64(6), "", "default", "", "", "text", "您好，已收到", int64(0), true, now, now},
+		}
+	})
+
+	s := handler.NewWechatServer("test-token")
+	autoReply := handler.NewAutoReply(s.AppID)
+	autoReply.Register(s)
+	post := newCallbackPoster(s)
+
+	text := func(content string) string {
+		return strings.Replace(textMsgXML, "<![CDATA[办事进度]]>", "<![CDATA["+content+"]]>", 1)
+	}
+	cases := []struct {
+		name, body, want string
+	}{
+		{"精确匹配", text(" 进度 "), "<Content><![CDATA[请登录查询办理进度]]></Content>"},
+		{"正则匹配", text("123456"), "<Content><![CDATA[验证码已收到]]></Content>"},
+		{"前缀匹配", text("办事指南"), "<Title><![CDATA[办事指南]]></Title>"},
+		{"未匹配的文本", text("你好"), "<Content><![CDATA[您好，已收到]]></Content>"},
+		{"图片消息", strings.Replace(textMsgXML, "<![CDATA[text]]>", "<![CDATA[image]]>", 1), "<Content><![CDATA[您好，已收到]]></Content>"},
+		{"关注", eventXML("openid-2", vxmsg.EventSubscribe, ""), "<Content><![CDATA[欢迎关注]]></Content>"},
+	}
+	for i, c := range cases {
+		// 每条消息使用不同的 MsgId，避免去重
+		body := strings.Replace(c.body, "1234567890123456", strings.Repeat("1", i+1), 1)
+		if w := post(body); !strings.Contains(w.Body.String(), c.want) {
+			t.Errorf("%s: 期望回复 %s，实际 %d %s", c.name, c.want, w.Code, w.Body.String())
+		}
+	}
+
+	// 管理接口：默认公众号的规则以空 appid 保存，保存后刷新本实例缓存
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	autoReply.RegisterAdminRoutes(r.Group("/admin/autoreply"))
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/autoreply/rules", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("W-AppID", "wx-default")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for _, body := range []string{
+		`{"rule_type":"keyword","match_type":"regex","keyword":"([","reply_type":"text","reply_content":"x"}`,
+		`{"rule_type":"keyword","reply_type":"text","reply_content":"x"}`,
+		`{"rule_type":"default","reply_type":"news","reply_content":"[{\"title\":\"a\"},{\"title\":\"b\"}]"}`,
+		`{"rule_type":"other","reply_type":"text","reply_content":"x"}`,
+	} {
+		if w := create(body); w.Code != http.StatusBadRequest {
+			t.Errorf("期望 400，实际 %d: %s", w.Code, body)
+		}
+	}
+
+	reloads := len(listed)
+	if w := create(`{"rule_type":"keyword","match_type":"exact","keyword":"地址","reply_type":"text","reply_content":"西安市"}`); w.Code != http.StatusOK {
+		t.Fatalf("新增规则失败: %d %s", w.Code, w.Body.String())
+	}
+	execs := fdb.execsMatching("INSERT INTO push_autoreply_rule")
+	if len(execs) != 1 || execs[0].args[0] != "" || execs[0].args[3] != "地址" {
+		t.Errorf("新增规则内容错误: %+v", execs)
+	}
+	if len(listed) != reloads+1 || listed[len(listed)-1] != "" {
+		t.Errorf("新增规则后应按空 appid 刷新缓存: %q", listed)
+	}
+}
@@ -0,0 +1,322 @@
+package test
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"vxmsgpush/api/handler"
+	"vxmsgpush/config"
+	"vxmsgpush/core/vxmsg"
+
+	"github.com/gin-gonic/gin"
+)
+
+const textMsgXML = `<xml>
+<ToUserName><![CDATA[gh_123456]]></ToUserName>
+<FromUserName><![CDATA[openid-1]]></FromUserName>
+<CreateTime>1348831860</CreateTime>
+<MsgType><![CDATA[text]]></MsgType>
+<Content><![CDATA[办事进度]]></Content>
+<MsgId>1234567890123456</MsgId>
+</xml>`
+
+func TestParseCallbackMessage(t *testing.T) {
+	msg, err := vxmsg.ParseCallbackMessage([]byte(textMsgXML))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if msg.MsgType != vxmsg.MsgTypeText || msg.Content != "办事进度" || msg.MsgID != 1234567890123456 {
+		t.Errorf("文本消息解析错误: %+v", msg)
+	}
+
+	msg, err = vxmsg.ParseCallbackMessage([]byte(`<xml>
+<ToUserName><![CDATA[gh_123456]]></ToUserName>
+<FromUserName><![CDATA[openid-1]]></FromUserName>
+<CreateTime>1395658920</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[TEMPLATESENDJOBFINISH]]></Event>
+<MsgID>200163836</MsgID>
+<Status><![CDATA[failed:user block]]></Status>
+</xml>`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if msg.Event != vxmsg.EventTemplateSendJobFinish || msg.TemplateMsgID != 200163836 || msg.Status != "failed:user block" {
+		t.Errorf("模板送达事件解析错误: %+v", msg)
+	}
+}
+
+func TestReplyBuilders(t *testing.T) {
+	msg := &vxmsg.CallbackMessage{ToUserName: "gh_123456", FromUserName: "openid-1"}
+
+	data, err := vxmsg.NewTextReply(msg, "您好").Bytes()
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	s := string(data)
+	for _, want := range []string{
+		"<xml>",
+		"<ToUserName><![CDATA[openid-1]]></ToUserName>",
+		"<FromUserName><![CDATA[gh_123456]]></FromUserName>",
+		"<MsgType><![CDATA[text]]></MsgType>",
+		"<Content><![CDATA[您好]]></Content>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("文本回复缺少 %s: %s", want, s)
+		}
+	}
+
+	data, _ = vxmsg.NewNewsReply(msg, vxmsg.Article{Title: "标题", URL: "https://example.com"}).Bytes()
+	s = string(data)
+	for _, want := range []string{
+		"<ArticleCount>1</ArticleCount>",
+		"<Articles><item><Title><![CDATA[标题]]></Title>",
+		"<Url><![CDATA[https://example.com]]></Url></item></Articles>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("图文回复缺少 %s: %s", want, s)
+		}
+	}
+
+	data, _ = vxmsg.NewImageReply(msg, "media-1").Bytes()
+	if !strings.Contains(string(data), "<Image><MediaId><![CDATA[media-1]]></MediaId></Image>") {
+		t.Errorf("图片回复格式错误: %s", data)
+	}
+}
+
+func signQuery(token, timestamp, nonce string) string {
+	list := []string{token, timestamp, nonce}
+	sort.Strings(list)
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(list, ""))))
+}
+
+func TestWechatServerRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := handler.NewWechatServer("test-token")
+	s.HandleMsg(vxmsg.MsgTypeText, func(msg *vxmsg.CallbackMessage) vxmsg.Reply {
+		return vxmsg.NewTextReply(msg, "收到："+msg.Content)
+	})
+	r := gin.New()
+	s.RegisterRoutes(r.Group("/wechat"))
+
+	post := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/wechat?"+query, strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	query := fmt.Sprintf("signature=%s&timestamp=%s&nonce=42", signQuery("test-token", ts, "42"), ts)
+
+	w := post(query, textMsgXML)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<Content><![CDATA[收到：办事进度]]></Content>") {
+		t.Errorf("文本消息回复错误: %d %s", w.Code, w.Body.String())
+	}
+
+	// 未注册处理函数的消息回复 success
+	w = post(query, strings.Replace(textMsgXML, "<![CDATA[text]]>", "<![CDATA[voice]]>", 1))
+	if w.Body.String() != "success" {
+		t.Errorf("期望回复 success，实际: %s", w.Body.String())
+	}
+
+	// 签名错误
+	if w = post("signature=bad&timestamp="+ts+"&nonce=1", textMsgXML); w.Code != http.StatusForbidden {
+		t.Errorf("期望 403，实际: %d", w.Code)
+	}
+
+	// 签名正确但时间戳过期（重放）
+	old := "1348831860"
+	if w = post(fmt.Sprintf("signature=%s&timestamp=%s&nonce=42", signQuery("test-token", old, "42"), old), textMsgXML); w.Code != http.StatusForbidden {
+		t.Errorf("过期请求期望 403，实际: %d", w.Code)
+	}
+}
+
+const testAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+func TestSafeModeCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, err := handler.NewWechatServerFromConfig(config.CallbackConfig{
+		Token:          "test-token",
+		EncodingAESKey: testAESKey,
+		Mode:           vxmsg.CallbackModeSafe,
+		AppID:          "wx123",
+	})
+	if err != nil {
+		t.Fatalf("创建回调服务失败: %v", err)
+	}
+	s.HandleMsg(vxmsg.MsgTypeText, func(msg *vxmsg.CallbackMessage) vxmsg.Reply {
+		return vxmsg.NewTextReply(msg, "收到："+msg.Content)
+	})
+	r := gin.New()
+	s.RegisterRoutes(r.Group("/wechat"))
+
+	crypter, _ := vxmsg.NewMsgCrypter("test-token", testAESKey, "wx123")
+	encrypt, err := crypter.Encrypt([]byte(textMsgXML))
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	body := fmt.Sprintf("<xml><ToUserName><![CDATA[gh_123456]]></ToUserName><Encrypt><![CDATA[%s]]></Encrypt></xml>", encrypt)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	query := fmt.Sprintf("signature=%s&timestamp=%s&nonce=42&encrypt_type=aes&msg_signature=%s",
+		signQuery("test-token", ts, "42"), ts, crypter.Signature(ts, "42", encrypt))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/wechat?"+query, strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望 200，实际: %d %s", w.Code, w.Body.String())
+	}
+
+	var reply vxmsg.EncryptedMessage
+	if err := xml.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("解析加密回复失败: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), crypter.Signature(ts, "42", reply.Encrypt)) {
+		t.Errorf("加密回复签名错误: %s", w.Body.String())
+	}
+	plain, err := crypter.Decrypt(reply.Encrypt)
+	if err != nil {
+		t.Fatalf("解密回复失败: %v", err)
+	}
+	if !strings.Contains(string(plain), "<Content><![CDATA[收到：办事进度]]></Content>") {
+		t.Errorf("回复内容错误: %s", plain)
+	}
+
+	// 安全模式拒绝明文消息
+	w = httptest.NewRecorder()
+	plainQuery := fmt.Sprintf("signature=%s&timestamp=%s&nonce=42", signQuery("test-token", ts, "42"), ts)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/wechat?"+plainQuery, strings.NewReader(textMsgXML)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("期望 403，实际: %d", w.Code)
+	}
+
+	// 其他 appid 加密的消息解密失败
+	other, _ := vxmsg.NewMsgCrypter("test-token", testAESKey, "wx456")
+	encrypt, _ = other.Encrypt([]byte(textMsgXML))
+	if _, err := crypter.Decrypt(encrypt); err == nil {
+		t.Errorf("期望 appid 不匹配时解密失败")
+	}
+}
+
+// eventXML 构造 openid 推送的事件消息，extra 为事件的其他字段
+func eventXML(openid, event, extra string) string {
+	return fmt.Sprintf(`<xml>
+<ToUserName><![CDATA[gh_123456]]></ToUserName>
+<FromUserName><![CDATA[%s]]></FromUserName>
+<CreateTime>1395658920</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[%s]]></Event>
+%s
+</xml>`, openid, event, extra)
+}
+
+// newCallbackPoster 返回以 test-token 签名向回调服务 POST 消息的函数
+func newCallbackPoster(s *handler.WechatServer) func(body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	s.RegisterRoutes(r.Group("/wechat"))
+	return func(body string) *httptest.ResponseRecorder {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		query := fmt.Sprintf("signature=%s&timestamp=%s&nonce=42", signQuery("test-token", ts, "42"), ts)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/wechat?"+query, strings.NewReader(body)))
+		return w
+	}
+}
+
+func TestCallbackDeliveryAppID(t *testing.T) {
+	useDefaultAppID(t, "wx-default")
+
+	finish := eventXML("openid-1", vxmsg.EventTemplateSendJobFinish, "<MsgID>200163836</MsgID><Status><![CDATA[success]]></Status>")
+	for _, c := range []struct {
+		appid, want string
+	}{
+		{"", ""},
+		{"wx-default", ""}, // 默认公众号与队列消息一样记为空 appid
+		{"wx-other", "wx-other"},
+	} {
+		useFakeRedis(t)
+		fdb := useFakeDB(t)
+		s, err := handler.NewWechatServerFromConfig(config.CallbackConfig{Token: "test-token", AppID: c.appid})
+		if err != nil {
+			t.Fatalf("创建回调服务失败: %v", err)
+		}
+		if s.AppID != c.want {
+			t.Errorf("appid %q: 回调服务 AppID 应为 %q，实际 %q", c.appid, c.want, s.AppID)
+		}
+		if w := newCallbackPoster(s)(finish); w.Code != http.StatusOK {
+			t.Fatalf("appid %q: 期望 200，实际 %d", c.appid, w.Code)
+		}
+		execs := fdb.execsMatching("INSERT INTO push_template_delivery")
+		if len(execs) != 1 || execs[0].args[0] != c.want || execs[0].args[1] != int64(200163836) {
+			t.Errorf("appid %q: 送达记录错误: %+v", c.appid, execs)
+		}
+	}
+}
+
+func TestCallbackRecordFailureRetried(t *testing.T) {
+	rdb, _ := useFakeRedis(t)
+	fdb := useFakeDB(t)
+	s, err := handler.NewWechatServerFromConfig(config.CallbackConfig{Token: "test-token"})
+	if err != nil {
+		t.Fatalf("创建回调服务失败: %v", err)
+	}
+	post := newCallbackPoster(s)
+	subscribe := eventXML("openid-1", vxmsg.EventSubscribe, "<EventKey><![CDATA[qrscene_123]]></EventKey>")
+
+	// 写入关注记录失败：回复非 success 并清除去重记录，等待微信重试
+	fdb.setExecErr(errors.New("数据库不可用"))
+	if w := post(subscribe); w.Code == http.StatusOK {
+		t.Fatalf("记录失败时不应回复 200: %s", w.Body.String())
+	}
+	if keys := rdb.keys("wx_callback_dedup"); len(keys) != 0 {
+		t.Fatalf("处理失败后应清除去重记录: %v", keys)
+	}
+
+	// 微信重试时重新处理并记录
+	fdb.setExecErr(nil)
+	if w := post(subscribe); w.Code != http.StatusOK || w.Body.String() != "success" {
+		t.Fatalf("重试时应处理成功: %d %s", w.Code, w.Body.String())
+	}
+	execs := fdb.execsMatching("INSERT INTO push_follower")
+	if len(execs) != 1 || execs[0].args[1] != "openid-1" || execs[0].args[2] != "123" {
+		t.Fatalf("关注记录错误: %+v", execs)
+	}
+	keys := rdb.keys("wx_callback_dedup")
+	if len(keys) != 1 {
+		t.Fatalf("处理成功后应保留去重记录: %v", keys)
+	}
+	if state, _ := rdb.get(keys[0]); state != "done" {
+		t.Errorf("去重记录状态应为 done，实际 %q", state)
+	}
+
+	// 已处理的推送不再重复记录
+	if w := post(subscribe); w.Code != http.StatusOK {
+		t.Fatalf("重复推送应回复 200，实际 %d", w.Code)
+	}
+	if n := len(fdb.execsMatching("INSERT INTO push_follower")); n != 1 {
+		t.Errorf("重复推送不应再次记录，实际记录 %d 次", n)
+	}
+
+	// 转发入队失败同样等待微信重试
+	useWebhooks(t, config.WebhookConfig{Name: "biz", URL: "http://127.0.0.1:1/hook"})
+	rdb.fail("RPUSH", true)
+	if w := post(textMsgXML); w.Code == http.StatusOK {
+		t.Fatalf("转发入队失败时不应回复 200: %s", w.Body.String())
+	}
+	rdb.fail("RPUSH", false)
+	if w := post(textMsgXML); w.Code != http.StatusOK {
+		t.Fatalf("重试时应处理成功，实际 %d", w.Code)
+	}
+	if n := len(rdb.list("wx_webhook_queue")); n != 1 {
+		t.Errorf("重试后应入队 1 条转发任务，实际 %d", n)
+	}
+}
@@ -0,0 +1,213 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"vxmsgpush/core/db"
+)
+
+// fakeDB 按 SQL 片段返回结果的内存数据库驱动，记录执行过的写语句
+type fakeDB struct {
+	mu      sync.Mutex
+	execs   []fakeExec
+	execErr error                                   // 不为 nil 时写语句均返回该错误，用于模拟数据库故障
+	queries map[string]fakeQuery                    // key 为 SQL 片段
+	onExec  func(query string, args []driver.Value) // 写语句执行后回调，可用于维护表状态
+}
+
+// fakeQuery 返回查询的列名与数据行
+type fakeQuery func(args []driver.Value) (columns []string, rows [][]driver.Value)
+
+// fakeExec 一条已执行的写语句
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = make(map[string]*fakeDB)
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// useFakeDB 将 db.DB 指向新的 fakeDB，测试结束后恢复
+func useFakeDB(t *testing.T) *fakeDB {
+	f := &fakeDB{queries: make(map[string]fakeQuery)}
+	fakeDBsMu.Lock()
+	name := fmt.Sprintf("%s-%d", t.Name(), len(fakeDBs))
+	fakeDBs[name] = f
+	fakeDBsMu.Unlock()
+
+	conn, err := sql.Open("fakedb", name)
+	if err != nil {
+		t.Fatalf("打开 fakedb 失败: %v", err)
+	}
+	old := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = old
+		conn.Close()
+	})
+	return f
+}
+
+// handle 为包含 fragment 的查询设置返回结果
+func (f *fakeDB) handle(fragment string, q fakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[fragment] = q
+}
+
+// setExecErr 设置写语句返回的错误，为 nil 时恢复正常
+func (f *fakeDB) setExecErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execErr = err
+}
+
+// execsMatching 返回包含 fragment 的写语句
+func (f *fakeDB) execsMatching(fragment string) []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeExec
+	for _, e := range f.execs {
+		if strings.Contains(e.query, fragment) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) exec(query string, args []driver.Value) error {
+	f.mu.Lock()
+	if f.execErr != nil {
+		err := f.execErr
+		f.mu.Unlock()
+		return err
+	}
+	f.execs = append(f.execs, fakeExec{query: query, args: args})
+	onExec := f.onExec
+	f.mu.Unlock()
+	if onExec != nil {
+		onExec(query, args)
+	}
+	return nil
+}
+
+func (f *fakeDB) query(query string, args []driver.Value) (driver.Rows, error) {
+	f.mu.Lock()
+	var q fakeQuery
+	for fragment, h := range f.queries {
+		if strings.Contains(query, fragment) {
+			q = h
+			break
+		}
+	}
+	f.mu.Unlock()
+	if q == nil {
+		return &fakeRows{}, nil
+	}
+	columns, rows := q(args)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	f, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("未注册的 fakedb: %s", name)
+	}
+	return &fakeConn{db: f}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.exec(query, namedValues(args)); err != nil {
+		return nil, err
+	}
+	return fakeResult(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query, namedValues(args))
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return values
+}
+
+// fakeResult 写语句的结果，自增 ID 与影响行数均为该值
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.db.exec(s.query, args); err != nil {
+		return nil, err
+	}
+	return fakeResult(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.db.query(s.query, args)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,333 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vxmsgpush/core/consumer"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis 仅实现队列、去重与延迟队列用到的命令的内存 Redis（RESP2）
+type fakeRedis struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	strs    map[string]string
+	lists   map[string][]string
+	zsets   map[string]map[string]float64
+	expires map[string]time.Time
+	failing map[string]bool // 返回错误的命令，用于模拟 Redis 故障
+}
+
+// newFakeRedis 启动 fakeRedis 并返回连接到它的客户端
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	f := &fakeRedis{
+		ln:      ln,
+		strs:    make(map[string]string),
+		lists:   make(map[string][]string),
+		zsets:   make(map[string]map[string]float64),
+		expires: make(map[string]time.Time),
+		failing: make(map[string]bool),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	t.Cleanup(func() {
+		rdb.Close()
+		ln.Close()
+	})
+	return f, rdb
+}
+
+// useFakeRedis 将 consumer.RDB 指向新的 fakeRedis，测试结束后恢复
+func useFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	f, rdb := newFakeRedis(t)
+	old := consumer.RDB
+	consumer.RDB = rdb
+	t.Cleanup(func() { consumer.RDB = old })
+	return f, rdb
+}
+
+// fail 设置命令（如 RPUSH）是否返回错误
+func (f *fakeRedis) fail(cmd string, failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[cmd] = failing
+}
+
+// get 返回字符串 key 的值
+func (f *fakeRedis) get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purgeExpired()
+	v, ok := f.strs[key]
+	return v, ok
+}
+
+// keys 返回以 prefix 开头的字符串 key
+func (f *fakeRedis) keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purgeExpired()
+	var keys []string
+	for k := range f.strs {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// list 返回列表内容
+func (f *fakeRedis) list(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.lists[key]...)
+}
+
+// zset 返回有序集合的成员及分数
+func (f *fakeRedis) zset(key string) map[string]float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]float64, len(f.zsets[key]))
+	for m, s := range f.zsets[key] {
+		out[m] = s
+	}
+	return out
+}
+
+// lpop 取出列表的第一个元素
+func (f *fakeRedis) lpop(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := f.lists[key]
+	if len(l) == 0 {
+		return "", false
+	}
+	f.lists[key] = l[1:]
+	return l[0], true
+}
+
+// rpush 直接向列表追加元素
+func (f *fakeRedis) rpush(key string, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists[key] = append(f.lists[key], values...)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+// exec 执行单条命令并返回 RESP 响应，BRPOP 在列表为空时轮询等待
+func (f *fakeRedis) exec(args []string) string {
+	cmd := strings.ToUpper(args[0])
+	if cmd == "BRPOP" {
+		timeout, _ := strconv.ParseFloat(args[len(args)-1], 64)
+		deadline := time.Now().Add(time.Duration(timeout * float64(time.Second)))
+		for {
+			if reply := f.execLocked(args); reply != "*-1\r\n" || time.Now().After(deadline) {
+				return reply
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return f.execLocked(args)
+}
+
+func (f *fakeRedis) execLocked(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purgeExpired()
+
+	cmd := strings.ToUpper(args[0])
+	if f.failing[cmd] {
+		return "-ERR injected failure\r\n"
+	}
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		v, ok := f.strs[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		// SET key value [NX] [EX s | PX ms]
+		key, nx := args[1], false
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			case "EX":
+				sec, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(sec) * time.Second
+				i++
+			}
+		}
+		if _, exists := f.strs[key]; nx && exists {
+			return "$-1\r\n"
+		}
+		f.strs[key] = args[2]
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.strs[key]; ok {
+				n++
+			}
+			delete(f.strs, key)
+			delete(f.lists, key)
+			delete(f.zsets, key)
+			delete(f.expires, key)
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "RPUSH":
+		f.lists[args[1]] = append(f.lists[args[1]], args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(f.lists[args[1]]))
+	case "LLEN":
+		return fmt.Sprintf(":%d\r\n", len(f.lists[args[1]]))
+	case "BRPOP":
+		for _, key := range args[1 : len(args)-1] {
+			l := f.lists[key]
+			if len(l) == 0 {
+				continue
+			}
+			v := l[len(l)-1]
+			f.lists[key] = l[:len(l)-1]
+			return "*2\r\n" + bulk(key) + bulk(v)
+		}
+		return "*-1\r\n"
+	case "ZADD":
+		z := f.zsets[args[1]]
+		if z == nil {
+			z = make(map[string]float64)
+			f.zsets[args[1]] = z
+		}
+		n := 0
+		for i := 2; i+1 < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			if _, ok := z[args[i+1]]; !ok {
+				n++
+			}
+			z[args[i+1]] = score
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "ZRANGEBYSCORE":
+		// ZRANGEBYSCORE key min max [LIMIT offset count]
+		min, _ := strconv.ParseFloat(args[2], 64)
+		max, _ := strconv.ParseFloat(args[3], 64)
+		var members []string
+		for m, s := range f.zsets[args[1]] {
+			if s >= min && s <= max {
+				members = append(members, m)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool {
+			return f.zsets[args[1]][members[i]] < f.zsets[args[1]][members[j]]
+		})
+		if len(args) == 7 && strings.ToUpper(args[4]) == "LIMIT" {
+			count, _ := strconv.Atoi(args[6])
+			if count >= 0 && count < len(members) {
+				members = members[:count]
+			}
+		}
+		out := fmt.Sprintf("*%d\r\n", len(members))
+		for _, m := range members {
+			out += bulk(m)
+		}
+		return out
+	case "ZREM":
+		n := 0
+		for _, m := range args[2:] {
+			if _, ok := f.zsets[args[1]][m]; ok {
+				n++
+				delete(f.zsets[args[1]], m)
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) purgeExpired() {
+	now := time.Now()
+	for key, at := range f.expires {
+		if !now.Before(at) {
+			delete(f.strs, key)
+			delete(f.expires, key)
+		}
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand 读取一条 RESP 数组命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("不支持的请求: %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		head, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(head[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
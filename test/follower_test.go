@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vxmsgpush/api/handler"
+	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/vxmsg"
+)
+
+// followerTable 根据写入的关注记录维护 push_follower 的 subscribed 状态
+type followerTable struct {
+	mu         sync.Mutex
+	subscribed map[string]bool // key 为 appid/openid
+}
+
+func useFollowerTable(fdb *fakeDB) *followerTable {
+	ft := &followerTable{subscribed: make(map[string]bool)}
+	fdb.onExec = func(query string, args []driver.Value) {
+		if !strings.Contains(query, "INSERT INTO push_follower") {
+			return
+		}
+		ft.mu.Lock()
+		defer ft.mu.Unlock()
+		ft.subscribed[args[0].(string)+"/"+args[1].(string)] = strings.Contains(query, "subscribed = 1")
+	}
+	fdb.handle("FROM push_follower", func(args []driver.Value) ([]string, [][]driver.Value) {
+		ft.mu.Lock()
+		defer ft.mu.Unlock()
+		subscribed, ok := ft.subscribed[args[0].(string)+"/"+args[1].(string)]
+		if !ok {
+			return []string{"subscribed"}, nil
+		}
+		return []string{"subscribed"}, [][]driver.Value{{subscribed}}
+	})
+	return ft
+}
+
+// openidLookupAddr 手机号查询 openid 接口的地址
+const openidLookupAddr = "192.170.144.52:9010"
+
+// useOpenIDLookup 将手机号查询 openid 的请求转给测试服务，按 openids（key 为手机号）返回结果，测试结束后恢复
+func useOpenIDLookup(t *testing.T, openids map[string]string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TxnBodyCom struct {
+				Mobile string `json:"mobile"`
+			} `json:"txnBodyCom"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		inner, _ := json.Marshal(map[string]string{"id": openids[req.TxnBodyCom.Mobile]})
+		json.NewEncoder(w).Encode(map[string]string{"C-Response-Body": string(inner)})
+	}))
+	t.Cleanup(srv.Close)
+
+	old := http.DefaultTransport
+	transport := old.(*http.Transport).Clone()
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == openidLookupAddr {
+			addr = srv.Listener.Addr().String()
+		}
+		return dial(ctx, network, addr)
+	}
+	http.DefaultTransport = transport
+	t.Cleanup(func() { http.DefaultTransport = old })
+}
+
+func TestFollowerSkipAndResubscribe(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	useGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		var msg vxmsg.TemplateMsg
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		sent = append(sent, msg.ToUser)
+		mu.Unlock()
+		if msg.ToUser == "openid-43004" {
+			writeWechatError(w, 43004)
+			return
+		}
+		writeWechatError(w, 0)
+	})
+	sentTo := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+
+	useOpenIDLookup(t, map[string]string{
+		"13800000001": "openid-1",
+		"13800000002": "openid-2",
+		"13800000003": "openid-43004",
+	})
+	fdb := useFakeDB(t)
+	useFollowerTable(fdb)
+	rdb, client := useFakeRedis(t)
+	const queue = "test_follower_queue"
+	consumer.StartRedisConsumers(client, queue, 1, 1, 1)
+
+	post := newCallbackPoster(handler.NewWechatServer("test-token"))
+	template := func(mobile string) string {
+		bs, _ := json.Marshal(consumer.RedisMessage{
+			RequestID:  "req-" + mobile,
+			Mobile:     mobile,
+			TemplateID: "tpl-1",
+			Data:       map[string]interface{}{"thing1": map[string]string{"value": "测试"}},
+		})
+		return string(bs)
+	}
+
+	// 取消关注后跳过：BRPOP 从队尾取出，openid-1 先于 openid-2 处理
+	if w := post(eventXML("openid-1", vxmsg.EventUnsubscribe, "")); w.Code != http.StatusOK {
+		t.Fatalf("取消关注回调失败: %d %s", w.Code, w.Body.String())
+	}
+	rdb.rpush(queue, template("13800000002"), template("13800000001"))
+	waitFor(t, "发给 openid-2 的消息", func() bool { return len(sentTo()) == 1 })
+	if got := sentTo(); got[0] != "openid-2" {
+		t.Fatalf("已取消关注的用户不应收到消息: %v", got)
+	}
+
+	// 重新关注后恢复发送
+	if w := post(eventXML("openid-1", vxmsg.EventSubscribe, "")); w.Code != http.StatusOK {
+		t.Fatalf("关注回调失败: %d %s", w.Code, w.Body.String())
+	}
+	rdb.rpush(queue, template("13800000001"))
+	waitFor(t, "重新关注后发给 openid-1 的消息", func() bool { return len(sentTo()) == 2 })
+	if got := sentTo(); got[1] != "openid-1" {
+		t.Fatalf("重新关注的用户应收到消息: %v", got)
+	}
+
+	// 43004 只进入重试，不改写关注状态（关注状态只由回调维护）
+	follows := len(fdb.execsMatching("push_follower"))
+	rdb.rpush(queue, template("13800000003"))
+	waitFor(t, "43004 消息进入延迟队列", func() bool { return len(rdb.zset("wx_template_msg_delay")) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(fdb.execsMatching("push_follower")); n != follows {
+		t.Errorf("43004 不应写入 push_follower，新增 %d 条", n-follows)
+	}
+}
+
+// waitFor 等待 cond 成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"vxmsgpush/config"
+	pb "vxmsgpush/core/grpc/token"
+	"vxmsgpush/core/vxmsg"
+
+	"google.golang.org/grpc"
+)
+
+// 微信接口前缀指向不存在的域名，请求经代理转给测试服务，用于验证前缀与代理配置
+const gatewayBaseURL = "http://wechat.test/weixin_api/"
+
+var (
+	gatewayOnce    sync.Once
+	gatewayMu      sync.Mutex
+	gatewayHandler http.HandlerFunc
+)
+
+// gatewayTokenService 测试用 TokenService，按 appid 返回固定的 token
+type gatewayTokenService struct {
+	pb.UnimplementedTokenServiceServer
+}
+
+func gatewayToken(appid string) *pb.TokenReply {
+	return &pb.TokenReply{AccessToken: "token-" + appid, ExpireAt: time.Now().Add(time.Hour).Unix(), Appid: appid}
+}
+
+func (gatewayTokenService) GetAccessToken(ctx context.Context, req *pb.TokenRequest) (*pb.TokenReply, error) {
+	return gatewayToken(req.Appid), nil
+}
+
+func (gatewayTokenService) WatchAccessToken(req *pb.TokenRequest, stream grpc.ServerStreamingServer[pb.TokenReply]) error {
+	if err := stream.Send(gatewayToken(req.Appid)); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+// useGateway 将包级函数使用的 TokenService 与微信接口指向测试服务，h 处理微信接口请求；
+// 微信接口配置只在首次使用时读取，测试服务在整个测试进程中共用
+func useGateway(t *testing.T, h http.HandlerFunc) {
+	gatewayOnce.Do(func() {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("监听失败: %v", err)
+		}
+		gs := grpc.NewServer()
+		pb.RegisterTokenServiceServer(gs, gatewayTokenService{})
+		go gs.Serve(lis)
+
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gatewayMu.Lock()
+			h := gatewayHandler
+			gatewayMu.Unlock()
+			if h == nil {
+				http.Error(w, "no handler", http.StatusBadGateway)
+				return
+			}
+			h(w, r)
+		}))
+
+		config.Conf.Token = config.TokenServiceConfig{Addr: lis.Addr().String()}
+		config.Conf.Wechat = config.WechatConfig{BaseURL: gatewayBaseURL, Timeout: 2, Proxy: proxy.URL}
+	})
+
+	gatewayMu.Lock()
+	gatewayHandler = h
+	gatewayMu.Unlock()
+	t.Cleanup(func() {
+		gatewayMu.Lock()
+		gatewayHandler = nil
+		gatewayMu.Unlock()
+	})
+}
+
+func TestWechatGatewayConfig(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []*http.Request
+	)
+	useGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r)
+		mu.Unlock()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok","msgid":1001}`))
+	})
+
+	for _, appid := range []string{"", "wx-b"} {
+		result, err := vxmsg.SendTemplateMsgWithAppID(appid, vxmsg.TemplateMsg{ToUser: "openid-1", TemplateID: "tpl-1"})
+		if err != nil || result.MsgID != 1001 {
+			t.Fatalf("appid %q: 发送失败: %v", appid, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 2 {
+		t.Fatalf("期望请求 2 次，实际 %d 次", len(reqs))
+	}
+	for i, appid := range []string{"", "wx-b"} {
+		r := reqs[i]
+		// 经代理转发的请求保留原始域名与去掉末尾 / 的前缀
+		if r.Host != "wechat.test" || r.URL.Path != "/weixin_api/cgi-bin/message/template/send" {
+			t.Errorf("appid %q: 请求地址错误: %s %s", appid, r.Host, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("access_token"); got != "token-"+appid {
+			t.Errorf("appid %q: 应使用该公众号的 token，实际 %s", appid, got)
+		}
+	}
+}
+
+// writeWechatError 按微信接口格式返回错误码
+func writeWechatError(w http.ResponseWriter, errcode int) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"errcode": errcode, "errmsg": "test"})
+}
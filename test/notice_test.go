@@ -0,0 +1,54 @@
+package test
+
+import (
+	"testing"
+
+	"vxmsgpush/api/handler"
+	"vxmsgpush/core/db"
+	"vxmsgpush/core/vxmsg"
+)
+
+func TestRenderNotice(t *testing.T) {
+	n := &db.NoticeTemplate{
+		Name:                "approval_progress",
+		Kind:                "template",
+		TemplateID:          "tpl-1",
+		Fields:              `{"thing1":"{{.applicant}}","thing2":"{{.item}}","phrase3":"{{.progress}}","thing4":"{{or (index . \"remark\") \"无\"}}"}`,
+		URL:                 "https://example.com/approval/{{.id}}",
+		MiniProgramAppID:    "wx-mini",
+		MiniProgramPagePath: "pages/approval?id={{.id}}",
+	}
+	vars := map[string]interface{}{"applicant": "张三", "item": "用章申请", "progress": "已通过", "id": 42}
+
+	var req handler.RedisTemplateMessage
+	if err := handler.RenderNotice(n, vars, &req); err != nil {
+		t.Fatalf("渲染失败: %v", err)
+	}
+	if req.TemplateID != "tpl-1" || req.Kind != "template" {
+		t.Errorf("模板信息错误: %+v", req)
+	}
+	if req.URL != "https://example.com/approval/42" {
+		t.Errorf("URL 渲染错误: %s", req.URL)
+	}
+	if req.MiniProgram == nil || req.MiniProgram.AppID != "wx-mini" || req.MiniProgram.PagePath != "pages/approval?id=42" {
+		t.Errorf("小程序跳转渲染错误: %+v", req.MiniProgram)
+	}
+	want := map[string]string{"thing1": "张三", "thing2": "用章申请", "phrase3": "已通过", "thing4": "无"}
+	for key, v := range want {
+		if got, _ := vxmsg.TemplateValue(req.Data[key]); got != v {
+			t.Errorf("字段 %s 渲染为 %q，期望 %q", key, got, v)
+		}
+	}
+
+	// 调用方指定的跳转地址优先
+	req = handler.RedisTemplateMessage{URL: "https://example.com/custom"}
+	if err := handler.RenderNotice(n, vars, &req); err != nil || req.URL != "https://example.com/custom" {
+		t.Errorf("应保留调用方指定的 URL: %s %v", req.URL, err)
+	}
+
+	// 缺少必需变量时渲染失败
+	delete(vars, "applicant")
+	if err := handler.RenderNotice(n, vars, &handler.RedisTemplateMessage{}); err == nil {
+		t.Errorf("缺少变量时应渲染失败")
+	}
+}
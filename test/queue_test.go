@@ -0,0 +1,159 @@
+package test
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vxmsgpush/api/handler"
+	"vxmsgpush/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const messageQueue = "wx_template_msg_queue"
+
+// postJSON 以 W-AppID 请求头调用 handler
+func postJSON(h gin.HandlerFunc, appid, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/", h)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if appid != "" {
+		req.Header.Set("W-AppID", appid)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// popQueued 取出队列中最早入队的消息
+func popQueued(t *testing.T, rdb *fakeRedis) map[string]interface{} {
+	t.Helper()
+	raw, ok := rdb.lpop(messageQueue)
+	if !ok {
+		t.Fatalf("队列中没有消息")
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("队列消息解析失败: %v", err)
+	}
+	return msg
+}
+
+// useDefaultAppID 设置默认公众号的 appid，测试结束后恢复
+func useDefaultAppID(t *testing.T, appid string) {
+	old := config.Conf.VxKey.AppId
+	config.Conf.VxKey.AppId = appid
+	t.Cleanup(func() { config.Conf.VxKey.AppId = old })
+}
+
+func TestPushTemplateQueuesNormalizedAppID(t *testing.T) {
+	useDefaultAppID(t, "wx-default")
+	rdb, _ := useFakeRedis(t)
+	fdb := useFakeDB(t)
+	var lookups []string
+	fdb.handle("FROM push_template", func(args []driver.Value) ([]string, [][]driver.Value) {
+		lookups = append(lookups, args[0].(string))
+		return []string{"appid", "template_id", "title", "primary_industry", "deputy_industry", "content", "example", "keywords", "synced_at"},
+			[][]driver.Value{{args[0], args[1], "审批通知", "", "", "{{thing1.DATA}}", "", `[{"key":"thing1","type":"thing","label":"事项"}]`, time.Now()}}
+	})
+
+	body := `{"mobile":"13800000000","template_id":"tpl-queue","data":{"thing1":{"value":"测试"}}}`
+	for _, c := range []struct {
+		header, want string
+	}{
+		{"", ""},
+		{"wx-default", ""}, // 默认公众号统一记为空 appid，与回调记录一致
+		{"wx-other", "wx-other"},
+	} {
+		w := postJSON(handler.PushTemplateHandlerRedis, c.header, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("W-AppID %q: 期望 200，实际 %d %s", c.header, w.Code, w.Body.String())
+		}
+		msg := popQueued(t, rdb)
+		if got, _ := msg["appid"].(string); got != c.want {
+			t.Errorf("W-AppID %q: 队列消息 appid 应为 %q，实际 %q", c.header, c.want, got)
+		}
+		if msg["type"] != "template" || msg["request_id"] == "" {
+			t.Errorf("W-AppID %q: 队列消息内容错误: %v", c.header, msg)
+		}
+	}
+	if strings.Join(lookups, ",") != ",wx-other" {
+		t.Errorf("模板应按规范化后的 appid 查询，实际 %q", lookups)
+	}
+}
+
+func TestPushSubscribeKind(t *testing.T) {
+	rdb, _ := useFakeRedis(t)
+	useFakeDB(t)
+
+	for _, body := range []string{
+		`{"kind":"notice","mobile":"13800000000","template_id":"tpl-1","data":{}}`,
+		`{"kind":"subscribe","mobile":"13800000000","template_id":"tpl-1"}`,
+		`{"kind":"subscribe","mobile":"13800000000","data":{}}`,
+	} {
+		if w := postJSON(handler.PushTemplateHandlerRedis, "", body); w.Code != http.StatusBadRequest {
+			t.Errorf("期望 400，实际 %d: %s", w.Code, body)
+		}
+	}
+	if n := len(rdb.list(messageQueue)); n != 0 {
+		t.Fatalf("校验失败的请求不应入队，实际 %d 条", n)
+	}
+
+	// 订阅通知不按公众号模板校验字段
+	w := postJSON(handler.PushTemplateHandlerRedis, "wx-b",
+		`{"kind":"subscribe","mobile":"13800000000","template_id":"sub-tpl","page":"pages/index","data":{"thing1":{"value":"测试"}}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望 200，实际 %d %s", w.Code, w.Body.String())
+	}
+	msg := popQueued(t, rdb)
+	if msg["type"] != "subscribe" || msg["template_id"] != "sub-tpl" || msg["page"] != "pages/index" || msg["appid"] != "wx-b" {
+		t.Errorf("订阅通知队列消息错误: %v", msg)
+	}
+}
+
+func TestPushCustomQueued(t *testing.T) {
+	useDefaultAppID(t, "wx-default")
+	rdb, _ := useFakeRedis(t)
+
+	for _, body := range []string{
+		`{"msgtype":"text","text":{"content":"您好"}}`,         // 缺少 touser 与 mobile
+		`{"touser":"openid-1","msgtype":"text"}`,             // 缺少 text
+		`{"touser":"openid-1","msgtype":"image","image":{}}`, // 缺少 media_id
+		`{"touser":"openid-1","msgtype":"unknown","text":{"content":"x"}}`,
+	} {
+		if w := postJSON(handler.PushCustomHandler, "", body); w.Code != http.StatusBadRequest {
+			t.Errorf("期望 400，实际 %d: %s", w.Code, body)
+		}
+	}
+	if n := len(rdb.list(messageQueue)); n != 0 {
+		t.Fatalf("校验失败的请求不应入队，实际 %d 条", n)
+	}
+
+	w := postJSON(handler.PushCustomHandler, "wx-default",
+		`{"request_id":"req-custom-1","touser":"openid-1","msgtype":"news","news":{"articles":[{"title":"标题","url":"https://example.com"}]}}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "req-custom-1") {
+		t.Fatalf("期望 200，实际 %d %s", w.Code, w.Body.String())
+	}
+	msg := popQueued(t, rdb)
+	custom, _ := msg["custom"].(map[string]interface{})
+	if msg["type"] != "custom" || msg["request_id"] != "req-custom-1" || msg["appid"] != nil || custom["touser"] != "openid-1" || custom["msgtype"] != "news" {
+		t.Errorf("客服消息队列消息错误: %v", msg)
+	}
+
+	// 只提供手机号时由 consumer 查询 openid
+	w = postJSON(handler.PushCustomHandler, "wx-b", `{"mobile":"13800000000","msgtype":"text","text":{"content":"您好"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望 200，实际 %d %s", w.Code, w.Body.String())
+	}
+	msg = popQueued(t, rdb)
+	if msg["mobile"] != "13800000000" || msg["appid"] != "wx-b" || msg["request_id"] == "" {
+		t.Errorf("客服消息队列消息错误: %v", msg)
+	}
+}
@@ -0,0 +1,309 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vxmsgpush/core/vxmsg"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeTokenSource 测试用 token 来源，Refresh 后返回新 token
+type fakeTokenSource struct {
+	token     string
+	refreshed int
+}
+
+func (f *fakeTokenSource) Token(ctx context.Context) (string, error) {
+	return f.token, nil
+}
+
+func (f *fakeTokenSource) Refresh(ctx context.Context, stale string) (string, error) {
+	f.refreshed++
+	f.token = "fresh-token"
+	return f.token, nil
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, ts vxmsg.TokenSource) *vxmsg.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return vxmsg.NewClient(
+		vxmsg.WithBaseURL(srv.URL),
+		vxmsg.WithHTTPClient(srv.Client()),
+		vxmsg.WithTokenSource(ts),
+		vxmsg.WithLogger(log),
+	)
+}
+
+func TestClientSendTemplate(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cgi-bin/message/template/send" {
+			t.Errorf("请求路径错误: %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("access_token"); got != "token-1" {
+			t.Errorf("access_token 错误: %s", got)
+		}
+		var msg vxmsg.TemplateMsg
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("请求体解析失败: %v", err)
+		}
+		if msg.ToUser != "openid-1" || msg.TemplateID != "tpl-1" {
+			t.Errorf("请求体内容错误: %+v", msg)
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok","msgid":200228332}`))
+	}, &fakeTokenSource{token: "token-1"})
+
+	result, err := client.SendTemplate(context.Background(), vxmsg.TemplateMsg{
+		ToUser:     "openid-1",
+		TemplateID: "tpl-1",
+		Data:       map[string]interface{}{"thing1": map[string]string{"value": "测试"}},
+	})
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if result.MsgID != 200228332 {
+		t.Errorf("msgid 错误: %d", result.MsgID)
+	}
+}
+
+func TestClientRetryOnInvalidToken(t *testing.T) {
+	for _, errcode := range []int{40001, 42001} {
+		var calls int
+		ts := &fakeTokenSource{token: "stale-token"}
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.URL.Query().Get("access_token") != "fresh-token" {
+				fmt.Fprintf(w, `{"errcode":%d,"errmsg":"invalid credential"}`, errcode)
+				return
+			}
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}, ts)
+
+		if err := client.SendText(context.Background(), "openid-1", "hello"); err != nil {
+			t.Fatalf("errcode %d: 发送失败: %v", errcode, err)
+		}
+		if ts.refreshed != 1 || calls != 2 {
+			t.Errorf("errcode %d: 期望刷新 1 次、请求 2 次，实际刷新 %d 次、请求 %d 次", errcode, ts.refreshed, calls)
+		}
+	}
+
+	// 刷新后仍失效时只重试一次，返回微信错误
+	var calls int
+	ts := &fakeTokenSource{token: "stale-token"}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"errcode":40001,"errmsg":"invalid credential"}`))
+	}, ts)
+	err := client.SendText(context.Background(), "openid-1", "hello")
+	if we, ok := err.(*vxmsg.WechatError); !ok || we.ErrCode != 40001 {
+		t.Errorf("期望返回 40001 WechatError，实际: %v", err)
+	}
+	if ts.refreshed != 1 || calls != 2 {
+		t.Errorf("期望只重试 1 次，实际刷新 %d 次、请求 %d 次", ts.refreshed, calls)
+	}
+}
+
+func TestClientWechatError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":43004,"errmsg":"require subscribe"}`))
+	}, &fakeTokenSource{token: "token-1"})
+
+	_, err := client.SendTemplate(context.Background(), vxmsg.TemplateMsg{ToUser: "openid-1", TemplateID: "tpl-1"})
+	we, ok := err.(*vxmsg.WechatError)
+	if !ok || we.ErrCode != 43004 {
+		t.Fatalf("期望返回 43004 WechatError，实际: %v", err)
+	}
+}
+
+func TestClientSendCustom(t *testing.T) {
+	var paths []string
+	var bodies []map[string]interface{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}, &fakeTokenSource{token: "token-1"})
+
+	err := client.SendCustom(context.Background(), vxmsg.CustomMsg{
+		ToUser:  "openid-1",
+		MsgType: vxmsg.CustomMsgMiniProgramPage,
+		MiniProgramPage: &vxmsg.CustomMiniProgramPage{
+			Title: "办事指南", AppID: "wxmini", PagePath: "pages/index", ThumbMediaID: "thumb-1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if err := client.Typing(context.Background(), "openid-1", true); err != nil {
+		t.Fatalf("下发输入状态失败: %v", err)
+	}
+
+	if paths[0] != "/cgi-bin/message/custom/send" || paths[1] != "/cgi-bin/message/custom/typing" {
+		t.Errorf("请求路径错误: %v", paths)
+	}
+	card, _ := bodies[0]["miniprogrampage"].(map[string]interface{})
+	if bodies[0]["msgtype"] != "miniprogrampage" || card["pagepath"] != "pages/index" || bodies[0]["text"] != nil {
+		t.Errorf("客服消息格式错误: %v", bodies[0])
+	}
+	if bodies[1]["command"] != "Typing" {
+		t.Errorf("输入状态格式错误: %v", bodies[1])
+	}
+
+	// 旧版 TextMsg 为 CustomMsg 的别名，仍可直接使用
+	if err := client.SendCustom(context.Background(), vxmsg.TextMsg{ToUser: "openid-1", MsgType: "text", Text: &vxmsg.CustomText{Content: "您好"}}); err != nil {
+		t.Fatalf("发送文本消息失败: %v", err)
+	}
+	if text, _ := bodies[2]["text"].(map[string]interface{}); text["content"] != "您好" {
+		t.Errorf("文本消息格式错误: %v", bodies[2])
+	}
+
+	// 内容不完整时不调用微信
+	if err := client.SendCustom(context.Background(), vxmsg.CustomMsg{ToUser: "openid-1", MsgType: vxmsg.CustomMsgImage}); err == nil || len(paths) != 3 {
+		t.Errorf("期望校验失败且不请求微信，err: %v，请求数: %d", err, len(paths))
+	}
+}
+
+func TestClientGetAllPrivateTemplates(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/cgi-bin/template/get_all_private_template" {
+			t.Errorf("请求错误: %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"template_list":[{"template_id":"tpl-1","title":"办件进度通知",
+			"content":"申请人：{{thing1.DATA}}\n办理事项:{{thing2.DATA}}\n办理时间{{time8.DATA}}\n{{first.DATA}}"}]}`))
+	}, &fakeTokenSource{token: "token-1"})
+
+	list, err := client.GetAllPrivateTemplates(context.Background())
+	if err != nil || len(list) != 1 {
+		t.Fatalf("获取模板失败: %v %v", err, list)
+	}
+
+	keywords := vxmsg.ParseTemplateKeywords(list[0].Content)
+	want := []vxmsg.TemplateKeyword{
+		{Key: "thing1", Type: "thing", Label: "申请人"},
+		{Key: "thing2", Type: "thing", Label: "办理事项"},
+		{Key: "time8", Type: "time", Label: "办理时间"},
+		{Key: "first", Type: "first", Label: ""},
+	}
+	if len(keywords) != len(want) {
+		t.Fatalf("字段数量错误: %+v", keywords)
+	}
+	for i := range want {
+		if keywords[i] != want[i] {
+			t.Errorf("第 %d 个字段错误: %+v，期望 %+v", i, keywords[i], want[i])
+		}
+	}
+}
+
+func TestValidateTemplateData(t *testing.T) {
+	keywords := []vxmsg.TemplateKeyword{
+		{Key: "thing1", Type: "thing", Label: "申请人"},
+		{Key: "time2", Type: "time", Label: "办理时间"},
+		{Key: "amount3", Type: "amount", Label: "金额"},
+		{Key: "phone_number4", Type: "phone_number", Label: "联系电话"},
+		{Key: "number5", Type: "number", Label: "数量"},
+		{Key: "remark", Type: "remark"},
+	}
+	value := func(v string) map[string]interface{} { return map[string]interface{}{"value": v} }
+
+	valid := map[string]interface{}{
+		"thing1":        value("张三"),
+		"time2":         value("2024年5月1日 09:30~2024年5月1日 18:00"),
+		"amount3":       value("¥100.50"),
+		"phone_number4": value("0571-88886666"),
+		"number5":       value("12.5"),
+	}
+	if errs := vxmsg.ValidateTemplateData(keywords, valid); len(errs) != 0 {
+		t.Errorf("期望校验通过，实际: %+v", errs)
+	}
+
+	invalid := map[string]interface{}{
+		"thing1":        value("这是一个超过二十个字符长度限制的事项名称示例文本"),
+		"time2":         value("明天上午"),
+		"amount3":       value("一百元"),
+		"phone_number4": value("138 0000 0000"),
+		"unknown":       value("x"),
+	}
+	errs := vxmsg.ValidateTemplateData(keywords, invalid)
+	got := make(map[string]bool)
+	for _, e := range errs {
+		got[e.Field] = true
+	}
+	for _, field := range []string{"thing1", "time2", "amount3", "phone_number4", "number5", "unknown"} {
+		if !got[field] {
+			t.Errorf("期望字段 %s 校验失败，实际: %+v", field, errs)
+		}
+	}
+	if got["remark"] {
+		t.Errorf("remark 字段不应为必填: %+v", errs)
+	}
+
+	// 数字值按文本校验，与微信接口的处理一致
+	numeric := map[string]interface{}{
+		"thing1":        value("张三"),
+		"time2":         value("2024年5月1日 09:30"),
+		"amount3":       map[string]interface{}{"value": 100.5},
+		"phone_number4": value("13800000000"),
+		"number5":       map[string]interface{}{"value": 123},
+	}
+	if errs := vxmsg.ValidateTemplateData(keywords, numeric); len(errs) != 0 {
+		t.Errorf("数字值应校验通过，实际: %+v", errs)
+	}
+	if v, ok := vxmsg.TemplateValue(map[string]interface{}{"value": float64(123)}); !ok || v != "123" {
+		t.Errorf("期望 123，实际: %q %v", v, ok)
+	}
+}
+
+func TestNormalizeTemplateData(t *testing.T) {
+	keywords := []vxmsg.TemplateKeyword{
+		{Key: "thing1", Type: "thing"},
+		{Key: "character_string2", Type: "character_string"},
+		{Key: "time3", Type: "time"},
+		{Key: "phone_number4", Type: "phone_number"},
+		{Key: "date5", Type: "date"},
+	}
+	data := map[string]interface{}{
+		"thing1":            map[string]interface{}{"value": "这是一个超过二十个字符长度限制的\n事项名称示例文本", "color": "#173177"},
+		"character_string2": map[string]interface{}{"value": "编号：SN-2024-0001"},
+		"time3":             map[string]interface{}{"value": "2024-05-01T09:30:00+08:00"},
+		"phone_number4":     map[string]interface{}{"value": "(0571) 8888 6666"},
+		"date5":             map[string]interface{}{"value": "2024年5月1日"},
+	}
+
+	out, changes := vxmsg.NormalizeTemplateData(keywords, data)
+	want := map[string]string{
+		"thing1":            "这是一个超过二十个字符长度限制的 事项…",
+		"character_string2": "SN-2024-0001",
+		"time3":             "2024年5月1日 09:30",
+		"phone_number4":     "057188886666",
+		"date5":             "2024年5月1日",
+	}
+	for field, v := range want {
+		got, _ := vxmsg.TemplateValue(out[field])
+		if got != v {
+			t.Errorf("字段 %s 规范化结果 %q，期望 %q", field, got, v)
+		}
+	}
+	if len(changes) != 4 {
+		t.Errorf("期望 4 个字段被修改，实际: %+v", changes)
+	}
+	if out["thing1"].(map[string]interface{})["color"] != "#173177" {
+		t.Errorf("规范化不应丢失 color: %v", out["thing1"])
+	}
+	if v, _ := vxmsg.TemplateValue(data["thing1"]); v == want["thing1"] {
+		t.Errorf("规范化不应修改入参")
+	}
+	if errs := vxmsg.ValidateTemplateData(keywords, out); len(errs) != 0 {
+		t.Errorf("规范化后应通过校验: %+v", errs)
+	}
+}
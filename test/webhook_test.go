@@ -0,0 +1,189 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vxmsgpush/config"
+	"vxmsgpush/core/consumer"
+	"vxmsgpush/core/vxmsg"
+)
+
+const webhookQueue = "wx_webhook_queue"
+
+// webhookJob 转发队列中的任务
+type webhookJob struct {
+	Webhook    string          `json:"webhook"`
+	ID         string          `json:"id"`
+	Payload    json.RawMessage `json:"payload"`
+	RetryCount int             `json:"retry_count"`
+}
+
+// useWebhooks 设置回调转发订阅，测试结束后恢复
+func useWebhooks(t *testing.T, webhooks ...config.WebhookConfig) {
+	old := config.Conf.Webhooks
+	config.Conf.Webhooks = webhooks
+	t.Cleanup(func() { config.Conf.Webhooks = old })
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// hex(HMAC-SHA256("secret", "1700000000." + body))
+	const want = "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := consumer.WebhookSignature("secret", "1700000000", []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("签名错误: %s", got)
+	}
+	if consumer.WebhookSignature("other", "1700000000", []byte(`{"id":"1"}`)) == want {
+		t.Errorf("不同密钥的签名不应相同")
+	}
+}
+
+func TestEnqueueWebhooksFilter(t *testing.T) {
+	useDefaultAppID(t, "wx-default")
+	useWebhooks(t,
+		config.WebhookConfig{Name: "all"},
+		config.WebhookConfig{Name: "text", MsgTypes: []string{vxmsg.MsgTypeText}},
+		config.WebhookConfig{Name: "click", Events: []string{vxmsg.EventClick}}, // 只订阅事件
+		config.WebhookConfig{Name: "app-b", AppIDs: []string{"wx-b"}},
+		config.WebhookConfig{Name: "default", AppIDs: []string{"wx-default"}, MsgTypes: []string{vxmsg.MsgTypeText}, Events: []string{vxmsg.EventClick}},
+	)
+	rdb, _ := useFakeRedis(t)
+
+	cases := []struct {
+		name  string
+		appid string
+		msg   *vxmsg.CallbackMessage
+		want  string
+	}{
+		{"默认公众号文本消息", "", &vxmsg.CallbackMessage{MsgType: vxmsg.MsgTypeText, MsgID: 1}, "all,default,text"},
+		{"默认公众号菜单点击", "", &vxmsg.CallbackMessage{MsgType: vxmsg.MsgTypeEvent, Event: vxmsg.EventClick, FromUserName: "openid-1", CreateTime: 2}, "all,click,default"},
+		{"其他公众号关注事件", "wx-b", &vxmsg.CallbackMessage{MsgType: vxmsg.MsgTypeEvent, Event: vxmsg.EventSubscribe, FromUserName: "openid-1", CreateTime: 3}, "all,app-b"},
+		{"其他公众号图片消息", "wx-c", &vxmsg.CallbackMessage{MsgType: vxmsg.MsgTypeImage, MsgID: 4}, "all"},
+	}
+	for _, c := range cases {
+		if err := consumer.EnqueueWebhooks(c.appid, c.msg); err != nil {
+			t.Fatalf("%s: 入队失败: %v", c.name, err)
+		}
+		var names []string
+		for {
+			raw, ok := rdb.lpop(webhookQueue)
+			if !ok {
+				break
+			}
+			var job webhookJob
+			if err := json.Unmarshal([]byte(raw), &job); err != nil {
+				t.Fatalf("%s: 任务解析失败: %v", c.name, err)
+			}
+			if job.ID != c.msg.DedupID() {
+				t.Errorf("%s: 任务 id 应为 %s，实际 %s", c.name, c.msg.DedupID(), job.ID)
+			}
+			names = append(names, job.Webhook)
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, ","); got != c.want {
+			t.Errorf("%s: 期望转发给 %s，实际 %s", c.name, c.want, got)
+		}
+	}
+}
+
+func TestWebhookRetryAndDeadLetter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reqs    []*http.Request
+		bodies  [][]byte
+		failing atomic.Bool
+	)
+	failing.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs, bodies = append(reqs, r), append(bodies, body)
+		mu.Unlock()
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	requests := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reqs)
+	}
+
+	useWebhooks(t, config.WebhookConfig{Name: "biz", URL: srv.URL, Secret: "secret", Timeout: 1, MaxRetry: 1})
+	rdb, client := useFakeRedis(t)
+	consumer.StartWebhookWorkers(client, 1)
+
+	msg := &vxmsg.CallbackMessage{MsgType: vxmsg.MsgTypeText, MsgID: 42, Content: "您好"}
+	if err := consumer.EnqueueWebhooks("wx-b", msg); err != nil {
+		t.Fatalf("入队失败: %v", err)
+	}
+
+	// 首次投递失败：进入延迟队列，retry_count 为 1
+	var delayed map[string]float64
+	waitFor(t, "投递失败的任务进入延迟队列", func() bool {
+		delayed = rdb.zset("wx_webhook_delay")
+		return len(delayed) == 1
+	})
+	var retried string
+	for member, score := range delayed {
+		retried = member
+		if wait := time.Until(time.Unix(int64(score), 0)); wait < 5*time.Second {
+			t.Errorf("重试时间过早: %v 后", wait)
+		}
+	}
+	var job webhookJob
+	json.Unmarshal([]byte(retried), &job)
+	if job.Webhook != "biz" || job.RetryCount != 1 {
+		t.Errorf("延迟任务错误: %+v", job)
+	}
+
+	// 请求携带签名，下游可按相同方式校验
+	mu.Lock()
+	req, body := reqs[0], bodies[0]
+	mu.Unlock()
+	if req.Header.Get("X-Webhook-ID") != "42" {
+		t.Errorf("X-Webhook-ID 错误: %s", req.Header.Get("X-Webhook-ID"))
+	}
+	if sig := consumer.WebhookSignature("secret", req.Header.Get("X-Webhook-Timestamp"), body); req.Header.Get("X-Webhook-Signature") != sig {
+		t.Errorf("签名错误: %s", req.Header.Get("X-Webhook-Signature"))
+	}
+	var payload struct {
+		ID      string                 `json:"id"`
+		AppID   string                 `json:"appid"`
+		Message *vxmsg.CallbackMessage `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.ID != "42" || payload.AppID != "wx-b" || payload.Message.Content != "您好" {
+		t.Errorf("转发内容错误: %s %v", body, err)
+	}
+
+	// 重试仍失败且超过 max_retry：进入死信队列
+	rdb.rpush(webhookQueue, retried)
+	waitFor(t, "超过重试次数的任务进入死信队列", func() bool { return len(rdb.list("wx_webhook_dlq")) == 1 })
+	json.Unmarshal([]byte(rdb.list("wx_webhook_dlq")[0]), &job)
+	if job.RetryCount != 2 {
+		t.Errorf("死信任务 retry_count 应为 2，实际 %d", job.RetryCount)
+	}
+
+	// 下游恢复后投递成功，不再重试
+	failing.Store(false)
+	msg.MsgID = 43
+	if err := consumer.EnqueueWebhooks("wx-b", msg); err != nil {
+		t.Fatalf("入队失败: %v", err)
+	}
+	waitFor(t, "投递成功", func() bool { return requests() == 3 })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rdb.zset("wx_webhook_delay")); n != 1 {
+		t.Errorf("投递成功后不应再进入延迟队列，延迟任务 %d 个", n)
+	}
+	if n := len(rdb.list("wx_webhook_dlq")); n != 1 {
+		t.Errorf("投递成功后不应进入死信队列，死信 %d 个", n)
+	}
+}
@@ -0,0 +1,6 @@
+package utils
+
+// IsTokenInvalid 判断微信错误码是否表示 access_token 无效（40001）或已过期（42001）
+func IsTokenInvalid(errcode int) bool {
+	return errcode == 40001 || errcode == 42001
+}